@@ -1,10 +1,15 @@
 package main
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/owenfeehan/geocoding-nominatim-cache/fetcher"
+	"github.com/owenfeehan/geocoding-nominatim-cache/jobs"
+	"github.com/owenfeehan/geocoding-nominatim-cache/location"
+	"github.com/owenfeehan/geocoding-nominatim-cache/router"
 	"github.com/owenfeehan/geocoding-nominatim-cache/store"
 )
 
@@ -12,6 +17,36 @@ import (
 type app struct {
 	Store   store.LocationStore
 	Fetcher fetcher.LocationFetcher
+
+	// Writes backups of the store, or nil if backups are disabled
+	Backups *store.BackupDirectory
+
+	// Reports whether fetching has been paused after repeated failures, or nil if there is no circuit-breaker
+	Breaker fetcher.Tripper
+
+	// The maximum number of queries in a batch
+	BatchMaxSize int
+
+	// The maximum number of uncached queries in a batch, or zero if unlimited, as the client waits while they are fetched
+	BatchMaxMisses int
+
+	// Processes jobs in the background, or nil if jobs are disabled
+	Jobs *jobs.Manager
+
+	// The maximum number of queries in a job
+	JobMaxSize int
+
+	// Counts the requests of each client, so the jobs they submit are limited as their requests are (optional)
+	Limiters *router.Limiters
+
+	// The type of the store backend e.g. badger or redis
+	Backend string
+
+	// The number of entries in the store, as last counted, or nil if not counted
+	CacheSize *storeSize
+
+	// When the application started
+	Started time.Time
 }
 
 // ErrorResponse is needed to document the error response for Swagger
@@ -22,26 +57,67 @@ type ErrorResponse struct {
 // forwardGeocode handles the /locations/:place endpoint.
 //
 // @Summary      Get location coordinates for a placename
-// @Description  get location coordinates and a canonical placename from a placename-query-string
+// @Description  get location coordinates and a canonical placename from a placename-query-string. With format=geojson (or Accept: application/geo+json), the location is a GeoJSON FeatureCollection, with a Point geometry and (when known) a bbox.
 // @Accept       json
 // @Produce      json
-// @Param        place   path      string  true  "query indicating a place or address"
-// @Success      200  {object}  location.Location
+// @Produce      application/geo+json
+// @Param        place              path    string  true   "query indicating a place or address"
+// @Param        format             query   string  false  "json (default) or geojson, which takes precedence over the Accept header"  Enums(json, geojson)
+// @Param        polygon_geojson    query   int     false  "1 to include the outline of the location as a GeoJSON geometry, which replaces the point in GeoJSON"  Enums(0, 1)
+// @Param        polygon_threshold  query   number  false  "simplifies the outline, so no point moves further than this many degrees"
+// @Param        countrycodes       query   string  false  "comma-separated ISO 3166-1 alpha-2 codes of the countries to which results are restricted e.g. us,ca"
+// @Param        viewbox            query   string  false  "the preferred area of results, as the longitude and latitude of two opposite corners: x1,y1,x2,y2"
+// @Param        bounded            query   int     false  "1 to restrict results to the viewbox"  Enums(0, 1)
+// @Param        featureType        query   string  false  "restricts results to a level of the address hierarchy"  Enums(country, state, city, settlement)
+// @Param        layer              query   string  false  "comma-separated themes to which results are restricted e.g. address,poi"
+// @Param        X-Priority         header  string  false  "bulk, to wait behind interactive requests to Nominatim"  Enums(interactive, bulk)
+// @Success      200  {object}  location.Location  "the location, or a location.FeatureCollection for GeoJSON"
 // @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse  "missing or invalid API key (only when API keys are required)"
+// @Failure      429  {object}  ErrorResponse  "a rate-limit or quota of the client is exceeded"
+// @Header       429  {integer}  Retry-After   "seconds until the request may be retried"
 // @Failure      500  {object}  ErrorResponse
+// @Security     ApiKeyAuth
 // @Router       /locations/{place} [get]
 func (a *app) ForwardGeocode(c *gin.Context) {
 	place := c.Param("place")
 	if place == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "place parameter is required"})
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "place parameter is required"})
 		return
 	}
-
-	loc, err := queryLocation(a.Store, a.Fetcher, place)
+	geoJSON, ok := wantsGeoJSON(c)
+	if !ok {
+		return
+	}
+	geometry, threshold, err := parseGeometryParams(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	filters, err := fetcher.ParseSearchFilters(c.Request.URL.Query())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 		return
 	}
 
+	req := fetcher.Search(place).WithParams(filters)
+	if geometry {
+		req = req.WithGeometry()
+	}
+	loc, err := queryLocation(c.Request.Context(), a.Store, a.Fetcher, req)
+	var limitErr *router.LimitError
+	if errors.As(err, &limitErr) {
+		router.AbortWithLimitError(c, limitErr)
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	loc = loc.Simplified(threshold)
+	if geoJSON {
+		renderGeoJSON(c, []location.Location{loc})
+		return
+	}
 	c.IndentedJSON(http.StatusOK, loc)
 }
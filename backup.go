@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owenfeehan/geocoding-nominatim-cache/store"
+)
+
+// BackupResponse describes a backup written by the /admin/backup endpoint.
+type BackupResponse struct {
+	Path string `json:"path" example:"/var/backups/geocoding/locations-20250101T120000.000000000Z.bak"`
+}
+
+// Backup handles the /admin/backup endpoint.
+//
+// @Summary      Write a backup of the location-store
+// @Description  writes a hot backup of the BadgerDB location-store into the backup directory, deleting the oldest backups beyond those to retain
+// @Produce      json
+// @Success      200  {object}  BackupResponse
+// @Failure      401  {object}  ErrorResponse  "missing or invalid API key"
+// @Failure      403  {object}  ErrorResponse  "the API key is not an admin key"
+// @Failure      500  {object}  ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /admin/backup [post]
+func (a *app) Backup(c *gin.Context) {
+	path, err := a.Backups.Backup()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, BackupResponse{Path: path})
+}
+
+// runRestore implements the restore subcommand, loading a backup into a fresh BadgerDB directory.
+//
+// Example:
+//
+//	geocoding-nominatim-cache restore --from /path/to/locations.bak --to /path/to/dir
+func runRestore(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	from := flags.String("from", "", "The backup file to restore from.")
+	to := flags.String("to", "", "The BadgerDB directory to restore into, which must not exist or be empty.")
+	debug := flags.Bool("debug", false, "Enable debug logging.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	configureLogging(*debug)
+
+	if *from == "" || *to == "" {
+		return errors.New("both --from and --to must be specified")
+	}
+
+	if err := store.RestoreBadgerBackup(*from, *to); err != nil {
+		return err
+	}
+
+	fmt.Printf("Restored %s into %s\n", *from, *to)
+	return nil
+}
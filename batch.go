@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owenfeehan/geocoding-nominatim-cache/fetcher"
+	"github.com/owenfeehan/geocoding-nominatim-cache/location"
+)
+
+// ndjsonContentType is the content-type of newline-delimited JSON, with one value per line.
+const ndjsonContentType = "application/x-ndjson"
+
+// errBatchTooLarge is returned when a batch contains more than the maximum number of queries.
+var errBatchTooLarge = errors.New("the batch contains too many queries")
+
+// tooManyMissesError is returned when a batch contains more uncached queries than may be fetched while the client waits.
+type tooManyMissesError struct {
+	misses    int
+	maxMisses int
+}
+
+func (e *tooManyMissesError) Error() string {
+	return fmt.Sprintf("the batch contains %d uncached queries, but at most %d may be fetched in a request", e.misses, e.maxMisses)
+}
+
+// BatchResult is the result of a single query in a batch, in the same position as the query.
+type BatchResult struct {
+	Query    string             `json:"query" example:"Brussels"`
+	Location *location.Location `json:"location,omitempty"`
+	Error    string             `json:"error,omitempty" example:"no locations found for query: Atlantis"`
+}
+
+// BatchGeocode handles the /locations/batch endpoint.
+//
+// @Summary      Get location coordinates for many placenames
+// @Description  get location coordinates for each query in a JSON array (or NDJSON stream) of placename-query-strings, with any search filters applied to every query. Cached queries are resolved immediately, and others are fetched one at a time from Nominatim. A result or error is returned for each query, in the same order, as JSON (or as NDJSON, for an NDJSON request).
+// @Accept       json
+// @Accept       application/x-ndjson
+// @Produce      json
+// @Produce      application/x-ndjson
+// @Param        queries       body   []string  true   "queries indicating places or addresses"
+// @Param        countrycodes  query  string    false  "comma-separated ISO 3166-1 alpha-2 codes of the countries to which results are restricted e.g. us,ca"
+// @Param        viewbox       query  string    false  "the preferred area of results, as the longitude and latitude of two opposite corners: x1,y1,x2,y2"
+// @Param        bounded       query  int       false  "1 to restrict results to the viewbox"  Enums(0, 1)
+// @Param        featureType   query  string    false  "restricts results to a level of the address hierarchy"  Enums(country, state, city, settlement)
+// @Param        layer         query  string    false  "comma-separated themes to which results are restricted e.g. address,poi"
+// @Success      200  {array}   BatchResult
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse  "missing or invalid API key (only when API keys are required)"
+// @Failure      413  {object}  ErrorResponse  "the batch contains too many queries, or too many uncached queries (which may be submitted as a job instead)"
+// @Failure      429  {object}  ErrorResponse  "a rate-limit or quota of the client is exceeded"
+// @Header       429  {integer}  Retry-After   "seconds until the request may be retried"
+// @Security     ApiKeyAuth
+// @Router       /locations/batch [post]
+func (a *app) BatchGeocode(c *gin.Context) {
+	ndjson := c.ContentType() == ndjsonContentType
+	filters, err := fetcher.ParseSearchFilters(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	queries, err := readBatch(c.Request.Body, ndjson, a.BatchMaxSize)
+	if errors.Is(err, errBatchTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{Error: fmt.Sprintf("a batch may contain at most %d queries", a.BatchMaxSize)})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	results, err := a.queryBatch(c.Request.Context(), queries, filters)
+	if err != nil {
+		message := err.Error()
+		if a.Jobs != nil {
+			message += "; submit the batch as a job to POST /jobs instead"
+		}
+		c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{Error: message})
+		return
+	}
+
+	if ndjson {
+		c.Header("Content-Type", ndjsonContentType)
+		c.Status(http.StatusOK)
+		encoder := json.NewEncoder(c.Writer)
+		for _, result := range results {
+			if err := encoder.Encode(result); err != nil {
+				return
+			}
+		}
+		return
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+// readBatch reads a JSON array of queries, or a NDJSON stream of queries, failing if there are more than maxSize.
+func readBatch(body io.Reader, ndjson bool, maxSize int) ([]string, error) {
+	decoder := json.NewDecoder(body)
+	if !ndjson {
+		if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+			return nil, errors.New("expected a JSON array of queries")
+		}
+	}
+
+	var queries []string
+	for decoder.More() {
+		if len(queries) == maxSize {
+			return nil, errBatchTooLarge
+		}
+
+		var query string
+		if err := decoder.Decode(&query); err != nil {
+			return nil, fmt.Errorf("expected each query to be a string: %w", err)
+		}
+		queries = append(queries, query)
+	}
+
+	if !ndjson {
+		if _, err := decoder.Token(); err != nil {
+			return nil, errors.New("expected a JSON array of queries")
+		}
+	}
+	return queries, nil
+}
+
+// queryBatch resolves every query from the cache, and then fetches the remainder one at a time.
+//
+// Fetching one at a time, rather than all at once, leaves room in the throttled queue for other clients.
+// Queries that occur several times are fetched only once. Every query is searched with the same filters.
+//
+// If there are more distinct misses than BatchMaxMisses, none are fetched and a tooManyMissesError is returned.
+func (a *app) queryBatch(ctx context.Context, queries []string, filters url.Values) ([]BatchResult, error) {
+	results := make([]BatchResult, len(queries))
+	misses := make(map[string][]int)
+	var missOrder []string
+
+	for i, query := range queries {
+		results[i].Query = query
+		if strings.TrimSpace(query) == "" {
+			results[i].Error = "the query is empty"
+			continue
+		}
+
+		locs, err := lookupCached(ctx, a.Store, fetcher.Search(query).WithParams(filters))
+		if err != nil {
+			results[i].Error = err.Error()
+		} else if locs != nil {
+			setBatchResult(&results[i], locs)
+		} else {
+			if _, ok := misses[query]; !ok {
+				missOrder = append(missOrder, query)
+			}
+			misses[query] = append(misses[query], i)
+		}
+	}
+
+	if a.BatchMaxMisses > 0 && len(missOrder) > a.BatchMaxMisses {
+		return nil, &tooManyMissesError{misses: len(missOrder), maxMisses: a.BatchMaxMisses}
+	}
+
+	// Misses are bulk work, so wait behind any interactive requests to Nominatim
+	ctx = fetcher.WithPriority(ctx, fetcher.PriorityBulk)
+	for _, query := range missOrder {
+		locs, err := fetchAndCache(ctx, a.Store, a.Fetcher, fetcher.Search(query).WithParams(filters))
+		for _, i := range misses[query] {
+			if err != nil {
+				results[i].Error = err.Error()
+			} else {
+				setBatchResult(&results[i], locs)
+			}
+		}
+	}
+	return results, nil
+}
+
+// setBatchResult sets the first location as the result, or an error if there are none.
+func setBatchResult(result *BatchResult, locs []location.Location) {
+	loc, err := extractFirstLocation(locs, result.Query)
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Location = &loc
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owenfeehan/geocoding-nominatim-cache/location"
+	"github.com/owenfeehan/geocoding-nominatim-cache/store"
+)
+
+func TestBatchGeocode(t *testing.T) {
+	locStore := store.NewMemoryStore()
+	_ = locStore.Set(context.Background(), "Brussels", []location.Location{{DisplayName: "Brussels, Belgium"}})
+
+	var fetched []string
+	a := &app{
+		Store: locStore,
+		Fetcher: &mockFetcher{fetchFunc: func(query string) ([]location.Location, error) {
+			fetched = append(fetched, query)
+			if query == "Atlantis" {
+				return nil, nil
+			} else if query == "Broken" {
+				return nil, errors.New("upstream error")
+			}
+			return []location.Location{{DisplayName: query + ", Somewhere"}}, nil
+		}},
+		BatchMaxSize: 10,
+	}
+
+	recorder := serveBatch(a, "application/json", `["Paris", "Brussels", "Atlantis", "Paris", "", "Broken"]`)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	var results []BatchResult
+	if err := json.Unmarshal(recorder.Body.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		displayName string
+		hasError    bool
+	}{
+		{"Paris, Somewhere", false},
+		{"Brussels, Belgium", false},
+		{"", true},
+		{"Paris, Somewhere", false},
+		{"", true},
+		{"", true},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(results))
+	}
+	for i, w := range want {
+		got := results[i]
+		if (got.Error != "") != w.hasError {
+			t.Errorf("result %d: unexpected error %q", i, got.Error)
+		}
+		if w.displayName != "" && (got.Location == nil || got.Location.DisplayName != w.displayName) {
+			t.Errorf("result %d: expected %s, got %+v", i, w.displayName, got.Location)
+		}
+	}
+
+	// Cached and repeated queries are not fetched
+	if strings.Join(fetched, ",") != "Paris,Atlantis,Broken" {
+		t.Errorf("expected to fetch each uncached query once, fetched %v", fetched)
+	}
+}
+
+func TestBatchGeocodeNDJSON(t *testing.T) {
+	a := &app{
+		Store: store.NewMemoryStore(),
+		Fetcher: &mockFetcher{fetchFunc: func(query string) ([]location.Location, error) {
+			return []location.Location{{DisplayName: query}}, nil
+		}},
+		BatchMaxSize: 10,
+	}
+
+	recorder := serveBatch(a, ndjsonContentType, "\"Paris\"\n\"Berlin\"\n")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if got := recorder.Header().Get("Content-Type"); got != ndjsonContentType {
+		t.Errorf("expected a NDJSON response, got %s", got)
+	}
+	lines := strings.Split(strings.TrimSpace(recorder.Body.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "Paris") || !strings.Contains(lines[1], "Berlin") {
+		t.Errorf("expected a line per query in order, got %q", lines)
+	}
+}
+
+func TestBatchGeocodeInvalid(t *testing.T) {
+	a := &app{Store: store.NewMemoryStore(), Fetcher: &mockFetcher{}, BatchMaxSize: 2}
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"too many queries", `["a", "b", "c"]`, http.StatusRequestEntityTooLarge},
+		{"not an array", `{"query": "a"}`, http.StatusBadRequest},
+		{"not strings", `[1, 2]`, http.StatusBadRequest},
+		{"unterminated", `["a"`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serveBatch(a, "application/json", tt.body).Code; got != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestBatchGeocodeTooManyMisses(t *testing.T) {
+	locStore := store.NewMemoryStore()
+	_ = locStore.Set(context.Background(), "Brussels", []location.Location{{DisplayName: "Brussels, Belgium"}})
+	fetches := 0
+	a := &app{
+		Store: locStore,
+		Fetcher: &mockFetcher{fetchFunc: func(query string) ([]location.Location, error) {
+			fetches++
+			return []location.Location{{DisplayName: query}}, nil
+		}},
+		BatchMaxSize:   10,
+		BatchMaxMisses: 2,
+	}
+
+	// Cached and repeated queries do not count towards the limit
+	if recorder := serveBatch(a, "application/json", `["Paris", "Brussels", "Paris", "Rome"]`); recorder.Code != http.StatusOK {
+		t.Errorf("expected status 200 within the limit, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	fetches = 0
+	recorder := serveBatch(a, "application/json", `["Berlin", "Madrid", "Lisbon"]`)
+	if recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413 beyond the limit, got %d", recorder.Code)
+	}
+	if fetches != 0 {
+		t.Errorf("expected nothing to be fetched beyond the limit, fetched %d", fetches)
+	}
+}
+
+// serveBatch posts a batch to the handler, and records the response.
+func serveBatch(a *app, contentType string, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/locations/batch", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", contentType)
+	a.BatchGeocode(c)
+	return recorder
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owenfeehan/geocoding-nominatim-cache/fetcher"
+	"github.com/rs/zerolog/log"
+)
+
+// csvContentType is the content-type of comma-separated values.
+const csvContentType = "text/csv"
+
+// csvResultColumns are appended to each row of a geocoded CSV.
+var csvResultColumns = []string{"lat", "lon", "display_name", "status"}
+
+// csvMaxRowSize is the maximum size in bytes of an average row of a CSV, which with the maximum number of rows limits
+// the size of its body.
+const csvMaxRowSize = 4096
+
+// csvStatusOK is the status of a row whose address was geocoded, as otherwise the status is an error.
+const csvStatusOK = "ok"
+
+// BatchGeocodeCSV handles the /locations/batch.csv endpoint.
+//
+// @Summary      Get location coordinates for each row of a CSV
+// @Description  get location coordinates for the address in each row of a CSV, whose first row is a header. The address is in the first column, unless other columns are named, whose values are joined with commas. The same CSV is returned with lat, lon, display_name and status columns appended, where the status is ok or an error. Rows are returned as they are geocoded, each from the cache or else fetched from Nominatim, with any search filters applied to every row. Once the maximum number of uncached addresses have been fetched, the status of each further uncached address is an error.
+// @Accept       text/csv
+// @Produce      text/csv
+// @Param        csv           body   string    true   "a CSV with a header row"
+// @Param        column        query  []string  false  "the columns of the address, in order (by default, the first column)"  collectionFormat(multi)
+// @Param        delimiter     query  string    false  "the character separating the values of a row (by default, a comma)"
+// @Param        countrycodes  query  string    false  "comma-separated ISO 3166-1 alpha-2 codes of the countries to which results are restricted e.g. us,ca"
+// @Param        viewbox       query  string    false  "the preferred area of results, as the longitude and latitude of two opposite corners: x1,y1,x2,y2"
+// @Param        bounded       query  int       false  "1 to restrict results to the viewbox"  Enums(0, 1)
+// @Param        featureType   query  string    false  "restricts results to a level of the address hierarchy"  Enums(country, state, city, settlement)
+// @Param        layer         query  string    false  "comma-separated themes to which results are restricted e.g. address,poi"
+// @Success      200  {string}  string  "the CSV with the results appended"
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse  "missing or invalid API key (only when API keys are required)"
+// @Failure      413  {object}  ErrorResponse  "the CSV contains too many rows, or is too large (unless detected after the response has begun, when the last row has only a status, describing the error)"
+// @Failure      429  {object}  ErrorResponse  "a rate-limit or quota of the client is exceeded"
+// @Header       429  {integer}  Retry-After   "seconds until the request may be retried"
+// @Security     ApiKeyAuth
+// @Router       /locations/batch.csv [post]
+func (a *app) BatchGeocodeCSV(c *gin.Context) {
+	delimiter := ','
+	if value := c.Query("delimiter"); value != "" {
+		r, size := utf8.DecodeRuneInString(value)
+		if size != len(value) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "delimiter must be a single character"})
+			return
+		}
+		delimiter = r
+	}
+
+	filters, err := fetcher.ParseSearchFilters(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	// The body is read a row at a time, while earlier rows are geocoded, so its size alone is limited in advance
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, int64(a.BatchMaxSize+1)*csvMaxRowSize)
+	reader := csv.NewReader(body)
+	reader.Comma = delimiter
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "expected a CSV with a header row"})
+		return
+	} else if err != nil {
+		c.JSON(csvErrorStatus(err), ErrorResponse{Error: csvErrorMessage(err, a.BatchMaxSize)})
+		return
+	}
+
+	columns, err := addressColumns(header, c.QueryArray("column"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	// The first row is read before responding, so a CSV that is invalid from the start is refused with an error status
+	row, err := readCSVRow(reader, 0, a.BatchMaxSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(csvErrorStatus(err), ErrorResponse{Error: csvErrorMessage(err, a.BatchMaxSize)})
+		return
+	}
+
+	// Later rows are read after the response has begun, which HTTP/1.x only allows in full-duplex mode
+	if err := http.NewResponseController(c.Writer).EnableFullDuplex(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Debug().Err(err).Msg("Cannot read the CSV while responding")
+	}
+
+	c.Header("Content-Type", csvContentType+"; charset=utf-8")
+	c.Status(http.StatusOK)
+	writer := csv.NewWriter(c.Writer)
+	writer.Comma = delimiter
+	if err := writer.Write(append(header, csvResultColumns...)); err != nil {
+		return
+	}
+
+	// Each row is written as soon as it is resolved, so a client sees progress through a large CSV
+	ctx := c.Request.Context()
+	misses := make(csvMisses)
+	for count := 1; err == nil; count++ {
+		if ctx.Err() != nil {
+			return
+		}
+		result := a.geocodeCSVRow(ctx, row, columns, filters, misses)
+		if !writeCSVRow(c, writer, append(row, csvResultRow(result)...)) {
+			return
+		}
+		row, err = readCSVRow(reader, count, a.BatchMaxSize)
+	}
+
+	// As the response has begun, an error is reported as a final row, with only a status
+	if !errors.Is(err, io.EOF) {
+		writeCSVRow(c, writer, append(make([]string, len(header)), csvResultRow(BatchResult{Error: csvErrorMessage(err, a.BatchMaxSize)})...))
+	}
+}
+
+// csvMisses holds the distinct addresses of a CSV that were not cached, and so were fetched.
+type csvMisses map[string]bool
+
+// allow determines whether an uncached address may be fetched, counting it if so, when at most maxMisses distinct
+// addresses may be fetched (or any number if zero). An address that was already fetched may be fetched again.
+func (m csvMisses) allow(address string, maxMisses int) bool {
+	if !m[address] && maxMisses > 0 && len(m) >= maxMisses {
+		return false
+	}
+	m[address] = true
+	return true
+}
+
+// geocodeCSVRow resolves the address in a row, from the cache or else by fetching it with the bulk priority.
+//
+// Once BatchMaxMisses distinct addresses have been fetched, further uncached addresses are not fetched, as the client
+// waits while each is fetched.
+func (a *app) geocodeCSVRow(ctx context.Context, row []string, columns []int, filters url.Values, misses csvMisses) BatchResult {
+	result := BatchResult{Query: joinColumns(row, columns)}
+	req := fetcher.Search(result.Query).WithParams(filters)
+	if result.Query == "" {
+		result.Error = "the address is empty"
+	} else if locs, err := lookupCached(ctx, a.Store, req); err != nil {
+		result.Error = err.Error()
+	} else if locs != nil {
+		setBatchResult(&result, locs)
+	} else if !misses.allow(result.Query, a.BatchMaxMisses) {
+		result.Error = a.csvTooManyMissesMessage()
+	} else if locs, err := fetchAndCache(fetcher.WithPriority(ctx, fetcher.PriorityBulk), a.Store, a.Fetcher, req); err != nil {
+		result.Error = err.Error()
+	} else {
+		setBatchResult(&result, locs)
+	}
+	return result
+}
+
+// csvTooManyMissesMessage is the status of a row whose address was not fetched, after BatchMaxMisses others.
+func (a *app) csvTooManyMissesMessage() string {
+	message := fmt.Sprintf("not fetched, as at most %d uncached addresses may be fetched in a request", a.BatchMaxMisses)
+	if a.Jobs != nil {
+		message += "; submit them as a job to POST /jobs instead"
+	}
+	return message
+}
+
+// readCSVRow reads the next row of a CSV, after count rows have been read, failing if there are more than maxSize rows.
+//
+// Every row must have as many values as the header. At the end of the CSV, io.EOF is returned.
+func readCSVRow(reader *csv.Reader, count int, maxSize int) ([]string, error) {
+	row, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	} else if count == maxSize {
+		return nil, errBatchTooLarge
+	}
+	return row, nil
+}
+
+// csvErrorStatus is the status of the response to a CSV that cannot be read.
+func csvErrorStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.Is(err, errBatchTooLarge) || errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// csvErrorMessage describes why a CSV cannot be read.
+func csvErrorMessage(err error, maxSize int) string {
+	var maxBytesErr *http.MaxBytesError
+	if errors.Is(err, errBatchTooLarge) {
+		return fmt.Sprintf("a CSV may contain at most %d rows", maxSize)
+	} else if errors.As(err, &maxBytesErr) {
+		return fmt.Sprintf("a CSV may contain at most %d bytes", maxBytesErr.Limit)
+	}
+	return err.Error()
+}
+
+// writeCSVRow writes a row to the response immediately, returning false if the client has gone.
+func writeCSVRow(c *gin.Context, writer *csv.Writer, row []string) bool {
+	if err := writer.Write(row); err != nil {
+		return false
+	}
+	writer.Flush()
+	if writer.Error() != nil {
+		return false
+	}
+	c.Writer.Flush()
+	return true
+}
+
+// addressColumns finds the indices of the named columns in the header, or the first column if none are named.
+func addressColumns(header []string, names []string) ([]int, error) {
+	if len(names) == 0 {
+		return []int{0}, nil
+	}
+	columns := make([]int, len(names))
+	for i, name := range names {
+		columns[i] = slices.Index(header, name)
+		if columns[i] < 0 {
+			return nil, fmt.Errorf("the CSV has no column named %q", name)
+		}
+	}
+	return columns, nil
+}
+
+// joinColumns joins the non-empty values of the columns in a row with commas, to form an address.
+func joinColumns(row []string, columns []int) string {
+	var values []string
+	for _, column := range columns {
+		if value := strings.TrimSpace(row[column]); value != "" {
+			values = append(values, value)
+		}
+	}
+	return strings.Join(values, ", ")
+}
+
+// csvResultRow renders a result as the values of the result columns.
+func csvResultRow(result BatchResult) []string {
+	if result.Location == nil {
+		return []string{"", "", "", result.Error}
+	}
+	return []string{result.Location.Latitude, result.Location.Longitude, result.Location.DisplayName, csvStatusOK}
+}
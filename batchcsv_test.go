@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owenfeehan/geocoding-nominatim-cache/location"
+	"github.com/owenfeehan/geocoding-nominatim-cache/store"
+)
+
+func TestBatchGeocodeCSV(t *testing.T) {
+	locStore := store.NewMemoryStore()
+	_ = locStore.Set(context.Background(), "Rue Neuve, Brussels", []location.Location{{DisplayName: "Rue Neuve", Latitude: "50.85", Longitude: "4.35"}})
+
+	var fetched []string
+	a := &app{
+		Store: locStore,
+		Fetcher: &mockFetcher{fetchFunc: func(query string) ([]location.Location, error) {
+			fetched = append(fetched, query)
+			if strings.Contains(query, "Atlantis") {
+				return nil, nil
+			}
+			return []location.Location{{DisplayName: query + ", Somewhere", Latitude: "1.5", Longitude: "2.5"}}, nil
+		}},
+		BatchMaxSize: 10,
+	}
+
+	body := "name;street;city\nShop;Rue Neuve;Brussels\nHome;\"Main St; 5\";Paris\nLost;;Atlantis\nNowhere;;\n"
+	recorder := serveBatchCSV(a, "?column=street&column=city&delimiter=%3B", body)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if got := recorder.Header().Get("Content-Type"); !strings.HasPrefix(got, csvContentType) {
+		t.Errorf("expected a CSV response, got %s", got)
+	}
+
+	want := "name;street;city;lat;lon;display_name;status\n" +
+		"Shop;Rue Neuve;Brussels;50.85;4.35;Rue Neuve;ok\n" +
+		"Home;\"Main St; 5\";Paris;1.5;2.5;\"Main St; 5, Paris, Somewhere\";ok\n" +
+		"Lost;;Atlantis;;;;no locations found for query: Atlantis\n" +
+		"Nowhere;;;;;;the address is empty\n"
+	if got := recorder.Body.String(); got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+	if strings.Join(fetched, "|") != "Main St; 5, Paris|Atlantis" {
+		t.Errorf("expected to fetch only uncached addresses, fetched %v", fetched)
+	}
+}
+
+func TestBatchGeocodeCSVTooManyMisses(t *testing.T) {
+	locStore := store.NewMemoryStore()
+	_ = locStore.Set(context.Background(), "Brussels", []location.Location{{DisplayName: "Brussels", Latitude: "50.85", Longitude: "4.35"}})
+
+	var fetched []string
+	a := &app{
+		Store: locStore,
+		Fetcher: &mockFetcher{fetchFunc: func(query string) ([]location.Location, error) {
+			fetched = append(fetched, query)
+			return []location.Location{{DisplayName: query, Latitude: "1.5", Longitude: "2.5"}}, nil
+		}},
+		BatchMaxSize:   10,
+		BatchMaxMisses: 1,
+	}
+
+	// Only the first uncached address is fetched, whereas cached addresses are still resolved
+	recorder := serveBatchCSV(a, "", "city\nParis\nBerlin\nBrussels\n")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	want := "city,lat,lon,display_name,status\n" +
+		"Paris,1.5,2.5,Paris,ok\n" +
+		"Berlin,,,,\"not fetched, as at most 1 uncached addresses may be fetched in a request\"\n" +
+		"Brussels,50.85,4.35,Brussels,ok\n"
+	if got := recorder.Body.String(); got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+	if strings.Join(fetched, "|") != "Paris" {
+		t.Errorf("expected to fetch only the first uncached address, fetched %v", fetched)
+	}
+}
+
+func TestBatchGeocodeCSVInvalid(t *testing.T) {
+	a := &app{Store: store.NewMemoryStore(), Fetcher: &mockFetcher{}, BatchMaxSize: 2}
+
+	tests := []struct {
+		name  string
+		query string
+		body  string
+		want  int
+	}{
+		{"too large", "", "address\n" + strings.Repeat("a", 3*csvMaxRowSize) + "\n", http.StatusRequestEntityTooLarge},
+		{"empty", "", "", http.StatusBadRequest},
+		{"unknown column", "?column=city", "address\na\n", http.StatusBadRequest},
+		{"uneven rows", "", "address,city\na\n", http.StatusBadRequest},
+		{"invalid delimiter", "?delimiter=ab", "address\na\n", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serveBatchCSV(a, tt.query, tt.body).Code; got != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestBatchGeocodeCSVStreamError(t *testing.T) {
+	a := &app{
+		Store:        store.NewMemoryStore(),
+		Fetcher:      &mockFetcher{fetchFunc: func(query string) ([]location.Location, error) { return []location.Location{{DisplayName: query}}, nil }},
+		BatchMaxSize: 2,
+	}
+
+	// After the response has begun, an error ends it with a final row
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"too many rows", "address,city\na,b\nc,d\ne,f\n", ",,,,,a CSV may contain at most 2 rows\n"},
+		{"uneven rows", "address,city\na,b\nc\n", ",,,,,invalid CSV: record on line 3: wrong number of fields\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := serveBatchCSV(a, "", tt.body)
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", recorder.Code)
+			}
+			if got := recorder.Body.String(); !strings.HasPrefix(got, "address,city,lat,lon,display_name,status\na,b,") || !strings.HasSuffix(got, tt.want) {
+				t.Errorf("expected the rows so far and then the error, got\n%s", got)
+			}
+		})
+	}
+}
+
+// serveBatchCSV posts a CSV to the handler with the query-string, and records the response.
+func serveBatchCSV(a *app, query string, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/locations/batch.csv"+query, strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", csvContentType)
+	a.BatchGeocodeCSV(c)
+	return recorder
+}
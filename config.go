@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadConfigFile sets flags from a YAML file, except those already set on the command line (which take precedence).
+//
+// The file maps the names of flags to their values, with lists joined by commas for flags accepting comma-separated values:
+//
+//	address: 0.0.0.0:8080
+//	badger-gc-interval: 10m
+//	cors-origins:
+//	  - https://maps.example.com
+func loadConfigFile(flags *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read config file: %w", err)
+	}
+
+	var values map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("cannot parse config file %s: %w", path, err)
+	}
+
+	setOnCommandLine := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		setOnCommandLine[f.Name] = true
+	})
+
+	for name, node := range values {
+		if flags.Lookup(name) == nil {
+			return fmt.Errorf("unknown option %q in config file %s", name, path)
+		} else if setOnCommandLine[name] {
+			continue
+		}
+
+		value, err := configValue(node)
+		if err != nil {
+			return fmt.Errorf("invalid value for %q in config file %s: %w", name, path, err)
+		}
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("invalid value for %q in config file %s: %w", name, path, err)
+		}
+	}
+	return nil
+}
+
+// configValue converts a scalar, or a list of scalars, to the string form of a flag's value.
+func configValue(node yaml.Node) (string, error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return node.Value, nil
+	case yaml.SequenceNode:
+		items := make([]string, len(node.Content))
+		for i, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return "", fmt.Errorf("expected a list of values, at line %d", item.Line)
+			}
+			items[i] = item.Value
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("expected a value or a list of values, at line %d", node.Line)
+	}
+}
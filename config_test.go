@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigFile(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	addr := flags.String("address", "localhost:8080", "")
+	throttle := flags.Int("throttle", 2000, "")
+	interval := flags.Duration("backup-interval", 0, "")
+	origins := flags.String("cors-origins", "", "")
+	if err := flags.Parse([]string{"--throttle", "3000"}); err != nil {
+		t.Fatal(err)
+	}
+
+	path := writeConfigFile(t, "address: 0.0.0.0:9090\nthrottle: 5000\nbackup-interval: 24h\ncors-origins:\n  - https://a.example.com\n  - https://b.example.com\n")
+	if err := loadConfigFile(flags, path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if *addr != "0.0.0.0:9090" {
+		t.Errorf("expected the address from the file, got %s", *addr)
+	}
+	if *throttle != 3000 {
+		t.Errorf("expected the command line to take precedence, got %d", *throttle)
+	}
+	if *interval != 24*time.Hour {
+		t.Errorf("expected a duration of 24h, got %v", *interval)
+	}
+	if *origins != "https://a.example.com,https://b.example.com" {
+		t.Errorf("expected a comma-separated list, got %s", *origins)
+	}
+}
+
+func TestLoadConfigFileInvalid(t *testing.T) {
+	tests := map[string]string{
+		"unknown option": "unknown: 1\n",
+		"invalid value":  "throttle: fast\n",
+		"nested value":   "throttle:\n  value: 1\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			flags := flag.NewFlagSet("test", flag.ContinueOnError)
+			flags.Int("throttle", 2000, "")
+			if err := loadConfigFile(flags, writeConfigFile(t, content)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+// Writes a config file with the given content.
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -19,42 +19,1276 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/backup": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "writes a hot backup of the BadgerDB location-store into the backup directory, deleting the oldest backups beyond those to retain",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Write a backup of the location-store",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.BackupResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "missing or invalid API key",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "the API key is not an admin key",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "checks whether the process is alive, without checking any dependencies",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Check liveness",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.HealthResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "submits a JSON array (or NDJSON stream) of placename-query-strings, to be geocoded in the background. Jobs are processed one at a time, in order of submission, and survive restarts of the service.",
+                "consumes": [
+                    "application/json",
+                    "application/x-ndjson"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Submit a job to geocode many placenames",
+                "parameters": [
+                    {
+                        "description": "queries indicating places or addresses",
+                        "name": "queries",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/jobs.Job"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "the URL of the job"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "missing or invalid API key (only when API keys are required)",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "the job contains too many queries",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "a rate-limit or quota of the client is exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "reports how many queries of a job have been processed, and (while unfinished) an estimate of when it will complete, assuming every remaining query must be fetched from Nominatim",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Report the progress of a job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "the ID of the job",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/jobs.Job"
+                        }
+                    },
+                    "401": {
+                        "description": "missing or invalid API key (only when API keys are required)",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "the job does not exist, or was submitted with another API key",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs/{id}/events": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "streams a result event for each query of a job as soon as it is processed, whose ID is the index of the result, until the job finishes with a done event. Heartbeat events report the progress of the job periodically. A reconnecting client resumes after the result in Last-Event-ID (or from offset).",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "summary": "Stream the results of a job as Server-Sent Events",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "the ID of the job",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "the index of the first result to stream",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "the index of the last result received, to resume after",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/jobs.Result"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "missing or invalid API key (only when API keys are required)",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "the job does not exist, or was submitted with another API key",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs/{id}/results": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "streams a result (with a location or an error) for each query of a job processed so far, in order, as NDJSON. Results may be retrieved incrementally with offset.",
+                "produces": [
+                    "application/x-ndjson"
+                ],
+                "summary": "Stream the results of a job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "the ID of the job",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "the index of the first result to return",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/jobs.Result"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "missing or invalid API key (only when API keys are required)",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "the job does not exist, or was submitted with another API key",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/locations/batch": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "get location coordinates for each query in a JSON array (or NDJSON stream) of placename-query-strings, with any search filters applied to every query. Cached queries are resolved immediately, and others are fetched one at a time from Nominatim. A result or error is returned for each query, in the same order, as JSON (or as NDJSON, for an NDJSON request).",
+                "consumes": [
+                    "application/json",
+                    "application/x-ndjson"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/x-ndjson"
+                ],
+                "summary": "Get location coordinates for many placenames",
+                "parameters": [
+                    {
+                        "description": "queries indicating places or addresses",
+                        "name": "queries",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "comma-separated ISO 3166-1 alpha-2 codes of the countries to which results are restricted e.g. us,ca",
+                        "name": "countrycodes",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "the preferred area of results, as the longitude and latitude of two opposite corners: x1,y1,x2,y2",
+                        "name": "viewbox",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            0,
+                            1
+                        ],
+                        "type": "integer",
+                        "description": "1 to restrict results to the viewbox",
+                        "name": "bounded",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "country",
+                            "state",
+                            "city",
+                            "settlement"
+                        ],
+                        "type": "string",
+                        "description": "restricts results to a level of the address hierarchy",
+                        "name": "featureType",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "comma-separated themes to which results are restricted e.g. address,poi",
+                        "name": "layer",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.BatchResult"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "missing or invalid API key (only when API keys are required)",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "the batch contains too many queries, or too many uncached queries (which may be submitted as a job instead)",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "a rate-limit or quota of the client is exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "seconds until the request may be retried"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/locations/batch.csv": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "get location coordinates for the address in each row of a CSV, whose first row is a header. The address is in the first column, unless other columns are named, whose values are joined with commas. The same CSV is returned with lat, lon, display_name and status columns appended, where the status is ok or an error. Rows are returned as they are geocoded, each from the cache or else fetched from Nominatim, with any search filters applied to every row. Once the maximum number of uncached addresses have been fetched, the status of each further uncached address is an error.",
+                "consumes": [
+                    "text/csv"
+                ],
+                "produces": [
+                    "text/csv"
+                ],
+                "summary": "Get location coordinates for each row of a CSV",
+                "parameters": [
+                    {
+                        "description": "a CSV with a header row",
+                        "name": "csv",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "the columns of the address, in order (by default, the first column)",
+                        "name": "column",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "the character separating the values of a row (by default, a comma)",
+                        "name": "delimiter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "comma-separated ISO 3166-1 alpha-2 codes of the countries to which results are restricted e.g. us,ca",
+                        "name": "countrycodes",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "the preferred area of results, as the longitude and latitude of two opposite corners: x1,y1,x2,y2",
+                        "name": "viewbox",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            0,
+                            1
+                        ],
+                        "type": "integer",
+                        "description": "1 to restrict results to the viewbox",
+                        "name": "bounded",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "country",
+                            "state",
+                            "city",
+                            "settlement"
+                        ],
+                        "type": "string",
+                        "description": "restricts results to a level of the address hierarchy",
+                        "name": "featureType",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "comma-separated themes to which results are restricted e.g. address,poi",
+                        "name": "layer",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "the CSV with the results appended",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "missing or invalid API key (only when API keys are required)",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "the CSV contains too many rows, or is too large (unless detected after the response has begun, when the last row has only a status, describing the error)",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "a rate-limit or quota of the client is exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "seconds until the request may be retried"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/locations/osm/{ids}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "gets the locations of OSM objects by their IDs, each prefixed by its type (N for node, W for way or R for relation). Each location is cached by its ID, and uncached IDs are fetched from Nominatim together, up to 50 per request. IDs without a location are omitted. With format=geojson (or Accept: application/geo+json), the locations are a GeoJSON FeatureCollection.",
+                "produces": [
+                    "application/json",
+                    "application/geo+json"
+                ],
+                "summary": "Get locations by OSM ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "comma-separated OSM IDs e.g. R58004,W50637691",
+                        "name": "ids",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "json",
+                            "geojson"
+                        ],
+                        "type": "string",
+                        "description": "json (default) or geojson, which takes precedence over the Accept header",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            0,
+                            1
+                        ],
+                        "type": "integer",
+                        "description": "1 to include the outline of each location as a GeoJSON geometry",
+                        "name": "polygon_geojson",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "simplifies outlines, so no point moves further than this many degrees",
+                        "name": "polygon_threshold",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "the locations, or a location.FeatureCollection for GeoJSON",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/location.Location"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "missing or invalid API key (only when API keys are required)",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "too many IDs",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "a rate-limit or quota of the client is exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "seconds until the request may be retried"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/locations/{place}": {
             "get": {
-                "description": "get location coordinates and a canonical placename from a placename-query-string",
-                "consumes": [
-                    "application/json"
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "get location coordinates and a canonical placename from a placename-query-string. With format=geojson (or Accept: application/geo+json), the location is a GeoJSON FeatureCollection, with a Point geometry and (when known) a bbox.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/geo+json"
+                ],
+                "summary": "Get location coordinates for a placename",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "query indicating a place or address",
+                        "name": "place",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "json",
+                            "geojson"
+                        ],
+                        "type": "string",
+                        "description": "json (default) or geojson, which takes precedence over the Accept header",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            0,
+                            1
+                        ],
+                        "type": "integer",
+                        "description": "1 to include the outline of the location as a GeoJSON geometry, which replaces the point in GeoJSON",
+                        "name": "polygon_geojson",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "simplifies the outline, so no point moves further than this many degrees",
+                        "name": "polygon_threshold",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "comma-separated ISO 3166-1 alpha-2 codes of the countries to which results are restricted e.g. us,ca",
+                        "name": "countrycodes",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "the preferred area of results, as the longitude and latitude of two opposite corners: x1,y1,x2,y2",
+                        "name": "viewbox",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            0,
+                            1
+                        ],
+                        "type": "integer",
+                        "description": "1 to restrict results to the viewbox",
+                        "name": "bounded",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "country",
+                            "state",
+                            "city",
+                            "settlement"
+                        ],
+                        "type": "string",
+                        "description": "restricts results to a level of the address hierarchy",
+                        "name": "featureType",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "comma-separated themes to which results are restricted e.g. address,poi",
+                        "name": "layer",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "interactive",
+                            "bulk"
+                        ],
+                        "type": "string",
+                        "description": "bulk, to wait behind interactive requests to Nominatim",
+                        "name": "X-Priority",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "the location, or a location.FeatureCollection for GeoJSON",
+                        "schema": {
+                            "$ref": "#/definitions/location.Location"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "missing or invalid API key (only when API keys are required)",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "a rate-limit or quota of the client is exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "seconds until the request may be retried"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/lookup": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "looks up places by their OSM IDs, each prefixed by its type (N for node, W for way or R for relation), with Nominatim's parameters and output formats. Each place is cached by its ID.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Look up places by OSM ID, like Nominatim",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "comma-separated OSM IDs (at most 50) e.g. R58004,W50637691",
+                        "name": "osm_ids",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "json",
+                            "jsonv2",
+                            "geojson",
+                            "geocodejson"
+                        ],
+                        "type": "string",
+                        "default": "jsonv2",
+                        "description": "output format",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            0,
+                            1
+                        ],
+                        "type": "integer",
+                        "description": "1 to include the components of each address",
+                        "name": "addressdetails",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "preferred languages of the results",
+                        "name": "accept-language",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            0,
+                            1
+                        ],
+                        "type": "integer",
+                        "description": "1 to include the outline of each place as a GeoJSON geometry",
+                        "name": "polygon_geojson",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "simplifies outlines, so no point moves further than this many degrees",
+                        "name": "polygon_threshold",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/location.JSONv2"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.NominatimErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "missing or invalid API key (only when API keys are required)",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "a rate-limit or quota of the client is exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "seconds until the request may be retried"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.NominatimErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "checks whether the service is ready to handle requests, i.e. the location-store is reachable and geocoding has not been paused after repeated failures",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Check readiness",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.ReadyResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/main.ReadyResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/reverse": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "finds the place nearest to a coordinate, with Nominatim's parameters and output formats. If there is none, the response is an error (with status 200), as for Nominatim.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find the place at a coordinate, like Nominatim",
+                "parameters": [
+                    {
+                        "type": "number",
+                        "description": "latitude",
+                        "name": "lat",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "number",
+                        "description": "longitude",
+                        "name": "lon",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 18,
+                        "description": "the level of detail, from 0 (country) to 18 (building)",
+                        "name": "zoom",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "json",
+                            "jsonv2",
+                            "geojson",
+                            "geocodejson"
+                        ],
+                        "type": "string",
+                        "default": "jsonv2",
+                        "description": "output format",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            0,
+                            1
+                        ],
+                        "type": "integer",
+                        "description": "1 to include the components of the address",
+                        "name": "addressdetails",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "preferred languages of the result",
+                        "name": "accept-language",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            0,
+                            1
+                        ],
+                        "type": "integer",
+                        "description": "1 to include the outline of each place as a GeoJSON geometry",
+                        "name": "polygon_geojson",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "simplifies outlines, so no point moves further than this many degrees",
+                        "name": "polygon_threshold",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/location.JSONv2"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.NominatimErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "missing or invalid API key (only when API keys are required)",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "a rate-limit or quota of the client is exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "seconds until the request may be retried"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.NominatimErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/search": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "searches for places by a free-text query (q) or a structured query, with Nominatim's parameters and output formats. At most 10 results are returned, as only Nominatim's default number of results is cached.",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Get location coordinates for a placename",
+                "summary": "Search for places, like Nominatim",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "query indicating a place or address",
-                        "name": "place",
-                        "in": "path",
-                        "required": true
+                        "description": "free-text query",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "name or type of a point of interest (structured query)",
+                        "name": "amenity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "house number and street name (structured query)",
+                        "name": "street",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "city (structured query)",
+                        "name": "city",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "county (structured query)",
+                        "name": "county",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "state (structured query)",
+                        "name": "state",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "country (structured query)",
+                        "name": "country",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "postal code (structured query)",
+                        "name": "postalcode",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "json",
+                            "jsonv2",
+                            "geojson",
+                            "geocodejson"
+                        ],
+                        "type": "string",
+                        "default": "jsonv2",
+                        "description": "output format",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "the maximum number of results",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "comma-separated ISO 3166-1 alpha-2 codes of the countries to which results are restricted e.g. us,ca",
+                        "name": "countrycodes",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "the preferred area of results, as the longitude and latitude of two opposite corners: x1,y1,x2,y2",
+                        "name": "viewbox",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            0,
+                            1
+                        ],
+                        "type": "integer",
+                        "description": "1 to restrict results to the viewbox",
+                        "name": "bounded",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "country",
+                            "state",
+                            "city",
+                            "settlement"
+                        ],
+                        "type": "string",
+                        "description": "restricts results to a level of the address hierarchy",
+                        "name": "featureType",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "comma-separated themes to which results are restricted e.g. address,poi",
+                        "name": "layer",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            0,
+                            1
+                        ],
+                        "type": "integer",
+                        "description": "1 to include the components of each address",
+                        "name": "addressdetails",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "preferred languages of the results",
+                        "name": "accept-language",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            0,
+                            1
+                        ],
+                        "type": "integer",
+                        "description": "1 to include the outline of each place as a GeoJSON geometry",
+                        "name": "polygon_geojson",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "simplifies outlines, so no point moves further than this many degrees",
+                        "name": "polygon_threshold",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/location.Location"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/location.JSONv2"
+                            }
                         }
                     },
                     "400": {
                         "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.NominatimErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "missing or invalid API key (only when API keys are required)",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "a rate-limit or quota of the client is exceeded",
                         "schema": {
                             "$ref": "#/definitions/main.ErrorResponse"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "seconds until the request may be retried"
+                            }
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/main.ErrorResponse"
+                            "$ref": "#/definitions/main.NominatimErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/status": {
+            "get": {
+                "description": "reports the status of the service, like Nominatim's /status endpoint, with the number of cached queries (as counted in the last few minutes), uptime, backend type and version. The response is plain text (OK or an error message) unless format=json.",
+                "produces": [
+                    "application/json",
+                    "text/plain"
+                ],
+                "summary": "Report status",
+                "parameters": [
+                    {
+                        "enum": [
+                            "text",
+                            "json"
+                        ],
+                        "type": "string",
+                        "description": "text (default) or json",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.StatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.StatusResponse"
                         }
                     }
                 }
@@ -62,17 +1296,262 @@ const docTemplate = `{
         }
     },
     "definitions": {
+        "jobs.Job": {
+            "type": "object",
+            "properties": {
+                "completed": {
+                    "type": "integer",
+                    "example": 2500
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "error": {
+                    "description": "Describes why the job failed, when the status is failed.",
+                    "type": "string"
+                },
+                "estimated_completion": {
+                    "description": "When the job is expected to complete, if every remaining query (including those of jobs ahead of it) must be\nfetched from Nominatim. Cached queries are faster, so this is an upper bound.",
+                    "type": "string"
+                },
+                "failed": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "9f86d081884c7d65"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/jobs.Status"
+                        }
+                    ],
+                    "example": "running"
+                },
+                "total": {
+                    "description": "The number of queries in the job, and how many have been processed (including any that failed).",
+                    "type": "integer",
+                    "example": 10000
+                }
+            }
+        },
+        "jobs.Result": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "no locations found for query: Atlantis"
+                },
+                "index": {
+                    "description": "The position of the query in the job.",
+                    "type": "integer",
+                    "example": 0
+                },
+                "location": {
+                    "$ref": "#/definitions/location.Location"
+                },
+                "query": {
+                    "type": "string",
+                    "example": "Brussels"
+                }
+            }
+        },
+        "jobs.Status": {
+            "type": "string",
+            "enum": [
+                "queued",
+                "running",
+                "completed",
+                "failed"
+            ],
+            "x-enum-varnames": [
+                "StatusQueued",
+                "StatusRunning",
+                "StatusCompleted",
+                "StatusFailed"
+            ]
+        },
+        "location.JSONv2": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "addresstype": {
+                    "type": "string",
+                    "example": "city"
+                },
+                "boundingbox": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "category": {
+                    "type": "string",
+                    "example": "boundary"
+                },
+                "display_name": {
+                    "type": "string",
+                    "example": "Brussels, Brussels-Capital, Belgium"
+                },
+                "geojson": {
+                    "type": "object"
+                },
+                "importance": {
+                    "type": "number",
+                    "example": 0.69
+                },
+                "lat": {
+                    "type": "string",
+                    "example": "50.8465573"
+                },
+                "licence": {
+                    "type": "string"
+                },
+                "lon": {
+                    "type": "string",
+                    "example": "4.351697"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Bruxelles - Brussel"
+                },
+                "osm_id": {
+                    "type": "integer",
+                    "example": 58004
+                },
+                "osm_type": {
+                    "type": "string",
+                    "example": "relation"
+                },
+                "place_id": {
+                    "type": "integer",
+                    "example": 98182699
+                },
+                "place_rank": {
+                    "type": "integer",
+                    "example": 16
+                },
+                "type": {
+                    "type": "string",
+                    "example": "administrative"
+                }
+            }
+        },
         "location.Location": {
             "type": "object",
             "properties": {
+                "address": {
+                    "description": "The components of the address of the location, by their Nominatim names e.g. road, city or country_code.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "addresstype": {
+                    "description": "The type of address of the location e.g. city, and its name in the local language.",
+                    "type": "string",
+                    "example": "city"
+                },
+                "boundingbox": {
+                    "description": "The area covering the location, as the minimum latitude, maximum latitude, minimum longitude and maximum\nlongitude, in that order.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "50.7963",
+                        "50.9136",
+                        "4.3139",
+                        "4.4369"
+                    ]
+                },
+                "class": {
+                    "description": "The main OSM tag of the location e.g. class boundary and type administrative.",
+                    "type": "string",
+                    "example": "boundary"
+                },
                 "display_name": {
                     "type": "string"
                 },
+                "geojson": {
+                    "description": "The outline of the location as a GeoJSON geometry e.g. a polygon, only if requested, as it can be large.",
+                    "type": "object"
+                },
+                "importance": {
+                    "description": "How important the location is, from 0 to 1, by which Nominatim ranks the results of a search.",
+                    "type": "number",
+                    "example": 0.69
+                },
                 "lat": {
                     "type": "string"
                 },
                 "lon": {
                     "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Bruxelles - Brussel"
+                },
+                "osm_id": {
+                    "type": "integer",
+                    "example": 58004
+                },
+                "osm_type": {
+                    "description": "The OSM object from which the location is derived, whose type is node, way or relation.",
+                    "type": "string",
+                    "example": "relation"
+                },
+                "place_id": {
+                    "description": "Identifies the location in the Nominatim database, which differs between Nominatim instances.",
+                    "type": "integer",
+                    "example": 98182699
+                },
+                "place_rank": {
+                    "description": "The search rank of the location, from 0 (largest e.g. a continent) to 30 (smallest e.g. a house).",
+                    "type": "integer",
+                    "example": 16
+                },
+                "type": {
+                    "type": "string",
+                    "example": "administrative"
+                }
+            }
+        },
+        "main.BackupResponse": {
+            "type": "object",
+            "properties": {
+                "path": {
+                    "type": "string",
+                    "example": "/var/backups/geocoding/locations-20250101T120000.000000000Z.bak"
+                }
+            }
+        },
+        "main.BatchResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "no locations found for query: Atlantis"
+                },
+                "location": {
+                    "$ref": "#/definitions/location.Location"
+                },
+                "query": {
+                    "type": "string",
+                    "example": "Brussels"
                 }
             }
         },
@@ -84,6 +1563,86 @@ const docTemplate = `{
                     "example": "invalid input"
                 }
             }
+        },
+        "main.HealthResponse": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "main.NominatimError": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "integer",
+                    "example": 400
+                },
+                "message": {
+                    "type": "string",
+                    "example": "Parameter 'lat' must be a number"
+                }
+            }
+        },
+        "main.NominatimErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "$ref": "#/definitions/main.NominatimError"
+                }
+            }
+        },
+        "main.ReadyResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "dial tcp 127.0.0.1:6379: connect: connection refused"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ready"
+                }
+            }
+        },
+        "main.StatusResponse": {
+            "type": "object",
+            "properties": {
+                "backend": {
+                    "type": "string",
+                    "example": "badger"
+                },
+                "cache_size": {
+                    "type": "integer",
+                    "example": 1024
+                },
+                "message": {
+                    "type": "string",
+                    "example": "OK"
+                },
+                "software_version": {
+                    "type": "string",
+                    "example": "1.2.0"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "uptime": {
+                    "type": "integer",
+                    "example": 3600
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "description": "Required only when the service is configured with API keys.",
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
         }
     }
 }`
@@ -0,0 +1,144 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+
+	location "github.com/owenfeehan/geocoding-nominatim-cache/location"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrCircuitOpen is returned by a circuit-breaker that has tripped, without calling its delegate.
+var ErrCircuitOpen = errors.New("geocoding is temporarily unavailable after repeated failures of the upstream API")
+
+// CircuitOpenError is ErrCircuitOpen, with how long until the breaker allows a trial call.
+type CircuitOpenError struct {
+	// Zero if the cooldown is over, but another call is the trial.
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return ErrCircuitOpen.Error()
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+// Tripper is implemented by fetchers that stop calling their delegate after repeated failures.
+type Tripper interface {
+	// Whether the fetcher has tripped, and is currently refusing to call its delegate.
+	Tripped() bool
+}
+
+// circuitBreaker wraps a LocationFetcher, and stops calling it for a cooldown period after consecutive failures.
+//
+// This avoids hammering an upstream API that is down or is rejecting requests (e.g. after exceeding a usage policy).
+type circuitBreaker struct {
+	delegate LocationFetcher
+
+	// The number of consecutive failures that trips the breaker.
+	threshold int
+
+	// How long to refuse calls after tripping, before a single trial call is allowed.
+	cooldown time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	trialing bool
+}
+
+// NewCircuitBreaker creates a new circuit-breaker that wraps the given delegate.
+//
+// After threshold consecutive failures, calls fail immediately with a CircuitOpenError for the cooldown period. Afterwards,
+// a single call is passed to the delegate, which either closes the breaker (if it succeeds) or trips it again.
+func NewCircuitBreaker(delegate LocationFetcher, threshold int, cooldown time.Duration) LocationFetcher {
+	return &circuitBreaker{delegate: delegate, threshold: threshold, cooldown: cooldown}
+}
+
+// Fetch calls the delegate's Fetch method, unless the breaker has tripped.
+func (b *circuitBreaker) Fetch(ctx context.Context, req Request) ([]location.Location, error) {
+	if retryAfter, ok := b.allow(); !ok {
+		return nil, &CircuitOpenError{RetryAfter: retryAfter}
+	}
+
+	locs, err := b.delegate.Fetch(ctx, req)
+	if err != nil && (ctx.Err() != nil || !isUpstreamFailure(err)) {
+		// The caller gave up, or the request itself was at fault, which says nothing about the upstream API
+		b.release()
+	} else {
+		b.record(err)
+	}
+	return locs, err
+}
+
+// Tripped returns true while the breaker is refusing calls, including while a trial call is in progress.
+func (b *circuitBreaker) Tripped() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures >= b.threshold
+}
+
+// allow determines whether a call may be passed to the delegate, or else how long until the cooldown is over.
+func (b *circuitBreaker) allow() (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return 0, true
+	}
+
+	// Allow a single trial call after the cooldown
+	remaining := b.cooldown - time.Since(b.openedAt)
+	if remaining > 0 {
+		return remaining, false
+	} else if b.trialing {
+		return 0, false
+	}
+	b.trialing = true
+	return 0, true
+}
+
+// release ends a trial call without recording its outcome, so another call may be the trial.
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trialing = false
+}
+
+// record updates the state of the breaker with the outcome of a call to the delegate.
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trialing = false
+	if err == nil {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openedAt = time.Now()
+		log.Warn().Err(err).Int("failures", b.failures).Dur("cooldown", b.cooldown).Msg("Circuit-breaker tripped, geocoding is paused")
+	}
+}
+
+// isUpstreamFailure determines whether an error is a failure of the upstream API: an unsuccessful status, or a failure to
+// reach it. Context errors are excluded, as the caller's deadline or cancellation is not the upstream's fault.
+func isUpstreamFailure(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var statusErr *StatusError
+	var urlErr *url.Error
+	return errors.As(err, &statusErr) || errors.As(err, &urlErr)
+}
+
+// Assert implementation
+var _ LocationFetcher = (*circuitBreaker)(nil)
+var _ Tripper = (*circuitBreaker)(nil)
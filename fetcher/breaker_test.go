@@ -0,0 +1,82 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	location "github.com/owenfeehan/geocoding-nominatim-cache/location"
+)
+
+// failingFetcher fails while fail is true, counting its calls.
+type failingFetcher struct {
+	fail  bool
+	calls int
+}
+
+func (f *failingFetcher) Fetch(_ context.Context, req Request) ([]location.Location, error) {
+	f.calls++
+	if f.fail {
+		return nil, &StatusError{StatusCode: 503, Body: "upstream unavailable"}
+	}
+	return []location.Location{{DisplayName: req.Query()}}, nil
+}
+
+func TestCircuitBreakerTripsAndRecovers(t *testing.T) {
+	delegate := &failingFetcher{fail: true}
+	breaker := NewCircuitBreaker(delegate, 2, 50*time.Millisecond)
+
+	// Failures up to the threshold are passed through
+	for range 2 {
+		if _, err := breaker.Fetch(context.Background(), Search("A")); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("expected the delegate's error, got %v", err)
+		}
+	}
+	if !breaker.(Tripper).Tripped() {
+		t.Fatal("expected the breaker to have tripped")
+	}
+
+	// While tripped, the delegate is not called
+	var openErr *CircuitOpenError
+	if _, err := breaker.Fetch(context.Background(), Search("A")); !errors.Is(err, ErrCircuitOpen) || !errors.As(err, &openErr) {
+		t.Errorf("expected ErrCircuitOpen, got %v", err)
+	} else if openErr.RetryAfter <= 0 || openErr.RetryAfter > 50*time.Millisecond {
+		t.Errorf("expected to retry after the rest of the cooldown, got %v", openErr.RetryAfter)
+	}
+	if delegate.calls != 2 {
+		t.Errorf("expected 2 calls to delegate, got %d", delegate.calls)
+	}
+
+	// After the cooldown, a successful trial closes the breaker
+	time.Sleep(60 * time.Millisecond)
+	delegate.fail = false
+	if _, err := breaker.Fetch(context.Background(), Search("A")); err != nil {
+		t.Errorf("expected the trial call to succeed, got %v", err)
+	}
+	if breaker.(Tripper).Tripped() {
+		t.Error("expected the breaker to have closed")
+	}
+}
+
+// cancelledFetcher fails as if the caller had cancelled the request.
+type cancelledFetcher struct{}
+
+func (cancelledFetcher) Fetch(ctx context.Context, _ Request) ([]location.Location, error) {
+	return nil, ctx.Err()
+}
+
+func TestCircuitBreakerIgnoresContextErrors(t *testing.T) {
+	breaker := NewCircuitBreaker(cancelledFetcher{}, 1, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for range 3 {
+		if _, err := breaker.Fetch(ctx, Search("A")); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected the context's error, got %v", err)
+		}
+	}
+	if breaker.(Tripper).Tripped() {
+		t.Error("expected the breaker not to trip on cancelled requests")
+	}
+}
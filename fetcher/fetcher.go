@@ -3,13 +3,20 @@
 // This may or may not involves calling an external geocoding API.
 package fetcher
 
-import location "github.com/owenfeehan/geocoding-nominatim-cache/location"
+import (
+	"context"
 
-// LocationFetcher is a polymorphic interface for fetching locations from a query.
+	location "github.com/owenfeehan/geocoding-nominatim-cache/location"
+)
+
+// instrumentationName identifies the spans created by this package (with the global tracer-provider).
+const instrumentationName = "github.com/owenfeehan/geocoding-nominatim-cache/fetcher"
+
+// LocationFetcher is a polymorphic interface for fetching locations from a request.
 //
 // Example:
 //
-//	NewNomnatimFetcher().Fetch("Galway, Ireland")
+//	NewNomnatimFetcher().Fetch(ctx, Search("Galway, Ireland"))
 type LocationFetcher interface {
-	Fetch(query string) ([]location.Location, error)
+	Fetch(ctx context.Context, req Request) ([]location.Location, error)
 }
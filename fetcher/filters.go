@@ -0,0 +1,109 @@
+package fetcher
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SearchFilters are the parameters of a search that restrict its results, with Nominatim's names.
+var SearchFilters = []string{"countrycodes", "viewbox", "bounded", "featureType", "layer"}
+
+// featureTypes are the values of the featureType filter, which restricts results to a level of the address hierarchy.
+var featureTypes = []string{"country", "state", "city", "settlement"}
+
+// layers are the values of the layer filter, which restricts results to themes of places.
+var layers = []string{"address", "poi", "railway", "natural", "manmade"}
+
+// countryCodePattern matches an ISO 3166-1 alpha-2 country code (after lowercasing).
+var countryCodePattern = regexp.MustCompile(`^[a-z]{2}$`)
+
+// ParseSearchFilters validates the search filters in params, ignoring any other parameters.
+//
+// The filters are normalised e.g. lists are sorted, so equivalent filters are the same in a cache key. Filters that are
+// empty, or have Nominatim's default value, are omitted.
+func ParseSearchFilters(params url.Values) (url.Values, error) {
+	filters := url.Values{}
+
+	if value := params.Get("countrycodes"); value != "" {
+		codes, err := parseList(strings.ToLower(value), func(code string) bool { return countryCodePattern.MatchString(code) })
+		if err != nil {
+			return nil, fmt.Errorf("countrycodes must be comma-separated ISO 3166-1 alpha-2 codes e.g. us,ca: %w", err)
+		}
+		filters.Set("countrycodes", codes)
+	}
+
+	if value := params.Get("viewbox"); value != "" {
+		viewbox, err := parseViewbox(value)
+		if err != nil {
+			return nil, err
+		}
+		filters.Set("viewbox", viewbox)
+	}
+
+	switch params.Get("bounded") {
+	case "", "0":
+	case "1":
+		if !filters.Has("viewbox") {
+			return nil, errors.New("bounded requires a viewbox")
+		}
+		filters.Set("bounded", "1")
+	default:
+		return nil, errors.New("bounded must be 0 or 1")
+	}
+
+	if value := strings.ToLower(strings.TrimSpace(params.Get("featureType"))); value != "" {
+		if !slices.Contains(featureTypes, value) {
+			return nil, fmt.Errorf("featureType must be one of: %s", strings.Join(featureTypes, ", "))
+		}
+		filters.Set("featureType", value)
+	}
+
+	if value := params.Get("layer"); value != "" {
+		list, err := parseList(strings.ToLower(value), func(layer string) bool { return slices.Contains(layers, layer) })
+		if err != nil {
+			return nil, fmt.Errorf("layer must be comma-separated values of: %s: %w", strings.Join(layers, ", "), err)
+		}
+		filters.Set("layer", list)
+	}
+	return filters, nil
+}
+
+// parseList validates each value in a comma-separated list, and joins the distinct values in sorted order.
+func parseList(value string, valid func(string) bool) (string, error) {
+	var values []string
+	for item := range strings.SplitSeq(value, ",") {
+		item = strings.TrimSpace(item)
+		if !valid(item) {
+			return "", fmt.Errorf("invalid value %q", item)
+		}
+		values = append(values, item)
+	}
+	slices.Sort(values)
+	return strings.Join(slices.Compact(values), ","), nil
+}
+
+// parseViewbox validates a viewbox, as the longitude and latitude of two opposite corners, and formats it consistently.
+func parseViewbox(value string) (string, error) {
+	corners := strings.Split(value, ",")
+	if len(corners) != 4 {
+		return "", errors.New("viewbox must be four comma-separated coordinates: x1,y1,x2,y2")
+	}
+	for i, corner := range corners {
+		coordinate, err := strconv.ParseFloat(strings.TrimSpace(corner), 64)
+		limit := 180.0
+		if i%2 == 1 {
+			limit = 90
+		}
+		if err != nil || math.IsNaN(coordinate) || math.Abs(coordinate) > limit {
+			return "", errors.New("viewbox must be four comma-separated coordinates: x1,y1,x2,y2")
+		}
+		corners[i] = strconv.FormatFloat(coordinate, 'f', -1, 64)
+	}
+	return strings.Join(corners, ","), nil
+}
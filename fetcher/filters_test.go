@@ -0,0 +1,60 @@
+package fetcher
+
+import (
+	"context"
+	"net/url"
+	"testing"
+)
+
+func TestParseSearchFilters(t *testing.T) {
+	tests := []struct {
+		params string
+		want   string
+	}{
+		{"q=Springfield", ""},
+		{"countrycodes=US,%20ca,us", "countrycodes=ca%2Cus"},
+		{"viewbox=-90.0,40,-89.50,39.5&bounded=1", "bounded=1&viewbox=-90%2C40%2C-89.5%2C39.5"},
+		{"viewbox=-90,40,-89.5,39.5&bounded=0", "viewbox=-90%2C40%2C-89.5%2C39.5"},
+		{"featureType=City&layer=poi,address", "featureType=city&layer=address%2Cpoi"},
+		{"countrycodes=&layer=", ""},
+	}
+	for _, test := range tests {
+		params, _ := url.ParseQuery(test.params)
+		filters, err := ParseSearchFilters(params)
+		if err != nil || filters.Encode() != test.want {
+			t.Errorf("%s: expected %q, got %q (%v)", test.params, test.want, filters.Encode(), err)
+		}
+	}
+
+	for _, invalid := range []string{
+		"countrycodes=usa", "viewbox=1,2,3", "viewbox=200,0,0,0", "bounded=1", "bounded=yes",
+		"featureType=street", "layer=poi,roads",
+	} {
+		params, _ := url.ParseQuery(invalid)
+		if _, err := ParseSearchFilters(params); err == nil {
+			t.Errorf("%s: expected an error", invalid)
+		}
+	}
+}
+
+func TestSearchFiltersKey(t *testing.T) {
+	params, _ := url.ParseQuery("layer=poi,address&countrycodes=us")
+	filters, err := ParseSearchFilters(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Filters are part of the key, so a filtered search is cached separately
+	req := Search("Springfield").WithParams(filters)
+	if got := req.Key(); got != "search?countrycodes=us&layer=address%2Cpoi&q=Springfield" {
+		t.Errorf("expected the filters in the key, got %s", got)
+	}
+	if got := Search("Springfield").WithParams(url.Values{}).Key(); got != "Springfield" {
+		t.Errorf("expected a search without filters to keep its key, got %s", got)
+	}
+
+	httpReq, err := buildNominatimRequest(context.Background(), req)
+	if err != nil || httpReq.URL.Query().Get("countrycodes") != "us" || httpReq.URL.Query().Get("layer") != "address,poi" {
+		t.Errorf("expected the filters to be sent to Nominatim, got %v (%v)", httpReq.URL, err)
+	}
+}
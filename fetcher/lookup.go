@@ -0,0 +1,113 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	location "github.com/owenfeehan/geocoding-nominatim-cache/location"
+	"github.com/owenfeehan/geocoding-nominatim-cache/store"
+	"github.com/rs/zerolog/log"
+)
+
+// MaxLookupIDs is the maximum number of OSM IDs in a single lookup, as allowed by Nominatim.
+const MaxLookupIDs = 50
+
+// osmIDPattern matches an OSM ID prefixed by its type, N (node), W (way) or R (relation).
+var osmIDPattern = regexp.MustCompile(`^[NWR][0-9]+$`)
+
+// ParseOSMIDs parses a comma-separated list of OSM IDs e.g. N123,W456, normalising their types to upper case.
+func ParseOSMIDs(value string) ([]string, error) {
+	var ids []string
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.ToUpper(strings.TrimSpace(id)); id == "" {
+			continue
+		} else if !osmIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("invalid OSM ID %q, which must be N, W or R followed by a number", id)
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("at least one OSM ID is required")
+	}
+	return ids, nil
+}
+
+// Lookup creates a request to look up locations by their OSM IDs (at most MaxLookupIDs), with any further parameters.
+func Lookup(ids []string, params url.Values) Request {
+	request := Request{Endpoint: EndpointLookup, Params: maps.Clone(params)}
+	if request.Params == nil {
+		request.Params = url.Values{}
+	}
+	request.Params.Set("osm_ids", strings.Join(ids, ","))
+	return request
+}
+
+// LookupRequests creates as few requests as possible to look up locations by their OSM IDs, with any further
+// parameters.
+func LookupRequests(ids []string, params url.Values) []Request {
+	var requests []Request
+	for batch := range slices.Chunk(ids, MaxLookupIDs) {
+		requests = append(requests, Lookup(batch, params))
+	}
+	return requests
+}
+
+// LookupCached looks up the locations of OSM IDs in the same order, omitting any IDs without a location.
+//
+// Each ID is cached separately in locStore, with any further parameters. Uncached IDs are fetched together, in as few
+// requests as possible, each once beforeFetch allows it (e.g. within the limits of a client).
+func LookupCached(ctx context.Context, locStore store.LocationStore, fetcher LocationFetcher, ids []string, params url.Values, beforeFetch func(context.Context) error) ([]location.Location, error) {
+	found := make(map[string][]location.Location, len(ids))
+	var misses []string
+	for _, id := range ids {
+		if _, ok := found[id]; ok {
+			continue
+		}
+		locs, err := locStore.Get(ctx, locStore.BuildKey(Lookup([]string{id}, params).Key()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve from the cache: %w", err)
+		} else if locs == nil {
+			misses = append(misses, id)
+			locs = []location.Location{}
+		}
+		found[id] = locs
+	}
+
+	for _, req := range LookupRequests(misses, params) {
+		if err := beforeFetch(ctx); err != nil {
+			return nil, err
+		}
+		locs, err := fetcher.Fetch(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch location: %w", err)
+		}
+		for _, loc := range locs {
+			if id := loc.OSMRef(); id != "" {
+				found[id] = append(found[id], loc)
+			}
+		}
+
+		// IDs without a location are also cached, so they are not fetched again
+		for _, id := range strings.Split(req.Params.Get("osm_ids"), ",") {
+			key := locStore.BuildKey(Lookup([]string{id}, params).Key())
+			if err := locStore.Set(ctx, key, found[id]); err != nil {
+				log.Error().Err(err).Str("id", id).Msg("Failed to cache the location of an OSM ID")
+			}
+		}
+	}
+
+	var locs []location.Location
+	for _, id := range ids {
+		locs = append(locs, found[id]...)
+	}
+	if locs == nil {
+		locs = []location.Location{}
+	}
+	return locs, nil
+}
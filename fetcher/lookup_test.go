@@ -0,0 +1,106 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	location "github.com/owenfeehan/geocoding-nominatim-cache/location"
+	"github.com/owenfeehan/geocoding-nominatim-cache/store"
+)
+
+func TestParseOSMIDs(t *testing.T) {
+	ids, err := ParseOSMIDs(" r58004, N123,,w456")
+	if want := []string{"R58004", "N123", "W456"}; err != nil || !reflect.DeepEqual(ids, want) {
+		t.Errorf("expected %v, got %v (%v)", want, ids, err)
+	}
+
+	for _, invalid := range []string{"", ",", "X123", "R", "R12a"} {
+		if _, err := ParseOSMIDs(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestLookupRequests(t *testing.T) {
+	var ids []string
+	for i := range 120 {
+		ids = append(ids, fmt.Sprintf("N%d", i))
+	}
+
+	requests := LookupRequests(ids, url.Values{"accept-language": {"fr"}})
+	if len(requests) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(requests))
+	}
+	for i, want := range []int{50, 50, 20} {
+		params := requests[i].Params
+		if got := len(strings.Split(params.Get("osm_ids"), ",")); got != want || params.Get("accept-language") != "fr" {
+			t.Errorf("expected request %d to look up %d IDs in French, got %v", i, want, params)
+		}
+	}
+
+	// The parameters are copied, rather than shared between requests
+	if requests[0].Params.Get("osm_ids") == requests[1].Params.Get("osm_ids") {
+		t.Error("expected each request to look up different IDs")
+	}
+}
+
+// nodeFetcher looks up only nodes, recording the number of IDs in each request.
+type nodeFetcher struct {
+	batches []int
+}
+
+func (f *nodeFetcher) Fetch(_ context.Context, req Request) ([]location.Location, error) {
+	ids := strings.Split(req.Params.Get("osm_ids"), ",")
+	f.batches = append(f.batches, len(ids))
+
+	var locs []location.Location
+	for _, id := range ids {
+		if number, ok := strings.CutPrefix(id, "N"); ok {
+			osmID, _ := strconv.ParseInt(number, 10, 64)
+			locs = append(locs, location.Location{DisplayName: id, OSMType: "node", OSMID: osmID})
+		}
+	}
+	return locs, nil
+}
+
+func TestLookupCached(t *testing.T) {
+	fetcher := &nodeFetcher{}
+	locStore := store.NewMemoryStore()
+	charged := 0
+	charge := func(context.Context) error {
+		charged++
+		return nil
+	}
+
+	var ids []string
+	for i := range 110 {
+		ids = append(ids, fmt.Sprintf("N%d", i+1))
+	}
+	ids = append(ids, "W1", "N1")
+
+	locs, err := LookupCached(context.Background(), locStore, fetcher, ids, nil, charge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(fetcher.batches) != "[50 50 11]" || charged != 3 {
+		t.Errorf("expected the IDs to be fetched (and charged) in batches of 50, got %v and %d charges", fetcher.batches, charged)
+	}
+	if len(locs) != 111 || locs[0].DisplayName != "N1" || locs[110].DisplayName != "N1" {
+		t.Errorf("expected a location for every node in order, got %d", len(locs))
+	}
+
+	// Each ID is cached, including those without a location
+	fetcher.batches = nil
+	locs, err = LookupCached(context.Background(), locStore, fetcher, []string{"N5", "W1", "N200"}, nil, charge)
+	if err != nil || len(locs) != 2 || locs[0].DisplayName != "N5" {
+		t.Errorf("unexpected locations %v (%v)", locs, err)
+	}
+	if fmt.Sprint(fetcher.batches) != "[1]" {
+		t.Errorf("expected only the uncached ID to be fetched, got %v", fetcher.batches)
+	}
+}
@@ -1,6 +1,7 @@
 package fetcher
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,27 +10,45 @@ import (
 
 	location "github.com/owenfeehan/geocoding-nominatim-cache/location"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
+// StatusError is returned when the Nominatim API responds with an unsuccessful HTTP status.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("nominatim responded with status %d: %s", e.StatusCode, e.Body)
+}
+
 // nominatimFetcher implements LocationFetcher using the Nominatim API.
-type nominatimFetcher struct{}
+type nominatimFetcher struct {
+	// Traces each request (as a span) when tracing is enabled
+	client *http.Client
+}
 
 // NewNomnatimFetcher creates a fetcher that geocodes locations using the Nominatim API.
 func NewNomnatimFetcher() LocationFetcher {
-	return &nominatimFetcher{}
+	return &nominatimFetcher{
+		client: &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)},
+	}
 }
 
-// Fetch fetches locations from the Nominatim API for the given query.
-func (f *nominatimFetcher) Fetch(query string) ([]location.Location, error) {
+// Fetch fetches locations from the Nominatim API for the given request.
+//
+// A reverse lookup returns at most one location.
+func (f *nominatimFetcher) Fetch(ctx context.Context, request Request) ([]location.Location, error) {
 
-	log.Debug().Str("Nominatim query", query).Msg("Fetching location from Nominatim")
+	log.Debug().Str("Nominatim query", request.Query()).Msg("Fetching location from Nominatim")
 
-	req, err := buildNominatimRequest(query)
+	req, err := buildNominatimRequest(ctx, request)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := f.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -43,17 +62,49 @@ func (f *nominatimFetcher) Fetch(query string) ([]location.Location, error) {
 
 	log.Debug().Str("Nominatim response", string(body)).Msg("Retrieving response from Nominatim")
 
-	var data []location.Location
-	if err := json.Unmarshal(body, &data); err != nil {
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	data, err := parseNominatimResponse(request.Endpoint, body)
+	if err != nil {
 		return nil, fmt.Errorf("cannot parse Nominatim response: %w\nThe response body was %s", err, string(body))
 	}
 	return data, nil
 }
 
-// buildNominatimRequest creates an HTTP GET request for the Nominatim API for the given query.
-func buildNominatimRequest(query string) (*http.Request, error) {
-	url := fmt.Sprintf("https://nominatim.openstreetmap.org/search?q=%s&format=json", url.QueryEscape(query))
-	req, err := http.NewRequest("GET", url, nil)
+// parseNominatimResponse parses the locations in the body of a response from an endpoint.
+func parseNominatimResponse(endpoint Endpoint, body []byte) ([]location.Location, error) {
+	if endpoint != EndpointReverse {
+		var data []location.Location
+		err := json.Unmarshal(body, &data)
+		return data, err
+	}
+
+	// A reverse lookup responds with a single location, or an error (with status 200) if there is none
+	var data struct {
+		location.Location
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	} else if data.Error != "" {
+		return []location.Location{}, nil
+	}
+	return []location.Location{data.Location}, nil
+}
+
+// buildNominatimRequest creates an HTTP GET request for the Nominatim API for the given request.
+//
+// The details of the address of each location are always requested, so they can be cached for any later request. The
+// outline of each location is only requested with GeometryParam, as it can be many times larger than the rest.
+func buildNominatimRequest(ctx context.Context, request Request) (*http.Request, error) {
+	params := url.Values{"format": {"json"}, "addressdetails": {"1"}}
+	for name, values := range request.Params {
+		params[name] = values
+	}
+	url := fmt.Sprintf("https://nominatim.openstreetmap.org/%s?%s", request.Endpoint, params.Encode())
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,53 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+)
+
+// Priority determines the order in which calls waiting to be throttled are delegated.
+type Priority int
+
+const (
+	// PriorityInteractive is for clients waiting on a response, which are delegated first. It is the default.
+	PriorityInteractive Priority = iota
+
+	// PriorityBulk is for bulk work (e.g. batches and jobs), which is delegated after any interactive calls, except
+	// to avoid starvation.
+	PriorityBulk
+)
+
+// Priorities lists every priority, from highest to lowest.
+var Priorities = []Priority{PriorityInteractive, PriorityBulk}
+
+// String names the priority, as parsed by ParsePriority.
+func (p Priority) String() string {
+	if p == PriorityBulk {
+		return "bulk"
+	}
+	return "interactive"
+}
+
+// ParsePriority parses the name of a priority, either interactive or bulk.
+func ParsePriority(name string) (Priority, error) {
+	for _, p := range Priorities {
+		if name == p.String() {
+			return p, nil
+		}
+	}
+	return PriorityInteractive, fmt.Errorf("unknown priority %q, expected interactive or bulk", name)
+}
+
+// priorityKey is the key in a context of its Priority.
+type priorityKey struct{}
+
+// WithPriority derives a context, whose calls to a throttler have the given priority.
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// PriorityFromContext returns the priority of calls with ctx, which is interactive unless set by WithPriority.
+func PriorityFromContext(ctx context.Context) Priority {
+	priority, _ := ctx.Value(priorityKey{}).(Priority)
+	return priority
+}
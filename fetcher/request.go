@@ -0,0 +1,92 @@
+package fetcher
+
+import (
+	"maps"
+	"net/url"
+	"strings"
+)
+
+// Endpoint is an endpoint of the Nominatim API that returns locations.
+type Endpoint string
+
+const (
+	// EndpointSearch finds locations by a free-text or structured query.
+	EndpointSearch Endpoint = "search"
+
+	// EndpointReverse finds the location nearest to a coordinate.
+	EndpointReverse Endpoint = "reverse"
+
+	// EndpointLookup finds locations by their OSM IDs.
+	EndpointLookup Endpoint = "lookup"
+)
+
+// endpoints are all the endpoints, whose names prefix the keys of requests.
+var endpoints = []Endpoint{EndpointSearch, EndpointReverse, EndpointLookup}
+
+// GeometryParam requests the outline of each location as a GeoJSON geometry, when set to 1.
+const GeometryParam = "polygon_geojson"
+
+// Request is a request to Nominatim for locations.
+type Request struct {
+	Endpoint Endpoint
+
+	// The parameters of the request, with Nominatim's names e.g. q for a search, or lat and lon for a reverse lookup.
+	//
+	// The output format is chosen by the fetcher, so is not included.
+	Params url.Values
+}
+
+// Search creates a request for a free-text search.
+func Search(query string) Request {
+	return Request{Endpoint: EndpointSearch, Params: url.Values{"q": {query}}}
+}
+
+// WithGeometry copies the request, additionally requesting the outline of each location.
+//
+// The outline is part of the key, so locations with and without outlines are cached separately.
+func (r Request) WithGeometry() Request {
+	return r.WithParams(url.Values{GeometryParam: {"1"}})
+}
+
+// WithParams copies the request, with additional parameters (which replace any of the same name) e.g. search filters.
+func (r Request) WithParams(params url.Values) Request {
+	request := Request{Endpoint: r.Endpoint, Params: maps.Clone(r.Params)}
+	if request.Params == nil {
+		request.Params = url.Values{}
+	}
+	for name, values := range params {
+		request.Params[name] = values
+	}
+	return request
+}
+
+// Query describes the request in logs and traces, by its free-text query if it has one.
+func (r Request) Query() string {
+	if r.Endpoint == EndpointSearch && r.Params.Has("q") {
+		return r.Params.Get("q")
+	}
+	return r.Key()
+}
+
+// Key identifies the request, e.g. as the key of its locations in a cache. Requests with the same key have the same
+// parameters, regardless of their order.
+//
+// A free-text search without other parameters is identified by its query alone, so locations cached before other
+// requests were supported remain valid. Any other request is identified by its endpoint and parameters, as is a query
+// that would otherwise be mistaken for them (e.g. "lookup?osm_ids=R58004"), so no two requests share a key.
+func (r Request) Key() string {
+	if r.Endpoint == EndpointSearch && len(r.Params) == 1 && len(r.Params["q"]) == 1 && !hasEndpointPrefix(r.Params.Get("q")) {
+		return r.Params.Get("q")
+	}
+	return string(r.Endpoint) + "?" + r.Params.Encode()
+}
+
+// hasEndpointPrefix determines whether a query starts like the key of a request identified by its parameters.
+func hasEndpointPrefix(query string) bool {
+	for _, endpoint := range endpoints {
+		if strings.HasPrefix(query, string(endpoint)+"?") {
+			return true
+		}
+	}
+	return false
+}
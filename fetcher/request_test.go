@@ -0,0 +1,73 @@
+package fetcher
+
+import (
+	"context"
+	"net/url"
+	"testing"
+)
+
+func TestRequestKey(t *testing.T) {
+	// A plain search is identified by its query, as before other requests were supported
+	if got := Search("Brussels").Key(); got != "Brussels" {
+		t.Errorf("expected the key of a search to be its query, got %s", got)
+	}
+
+	first := Request{Endpoint: EndpointReverse, Params: url.Values{"lat": {"50.85"}, "lon": {"4.35"}}}
+	second := Request{Endpoint: EndpointReverse, Params: url.Values{"lon": {"4.35"}, "lat": {"50.85"}}}
+	if first.Key() != second.Key() || first.Key() != "reverse?lat=50.85&lon=4.35" {
+		t.Errorf("expected equal keys regardless of order, got %s and %s", first.Key(), second.Key())
+	}
+
+	structured := Request{Endpoint: EndpointSearch, Params: url.Values{"city": {"Brussels"}}}
+	if got := structured.Key(); got != "search?city=Brussels" {
+		t.Errorf("expected a structured search to be identified by its parameters, got %s", got)
+	}
+}
+
+func TestRequestKeyCollision(t *testing.T) {
+	lookup := Request{Endpoint: EndpointLookup, Params: url.Values{"osm_ids": {"R58004"}}}
+	search := Search(lookup.Key())
+	if search.Key() == lookup.Key() {
+		t.Fatalf("expected a query resembling a lookup to have a different key, got %s for both", lookup.Key())
+	}
+	if got := search.Key(); got != "search?q=lookup%3Fosm_ids%3DR58004" {
+		t.Errorf("expected the query to be identified by its parameters, got %s", got)
+	}
+	if got := Search("reverse geocoding").Key(); got != "reverse geocoding" {
+		t.Errorf("expected other queries to be identified by themselves, got %s", got)
+	}
+}
+
+func TestRequestWithGeometry(t *testing.T) {
+	search := Search("Brussels")
+	withGeometry := search.WithGeometry()
+	if search.Params.Has(GeometryParam) || withGeometry.Key() != "search?polygon_geojson=1&q=Brussels" {
+		t.Errorf("expected a copy of the request with a separate key, got %s", withGeometry.Key())
+	}
+
+	req, err := buildNominatimRequest(context.Background(), withGeometry)
+	if err != nil || req.URL.Query().Get(GeometryParam) != "1" || req.URL.Query().Get("q") != "Brussels" {
+		t.Errorf("expected the outline to be requested from Nominatim, got %v (%v)", req.URL, err)
+	}
+	req, err = buildNominatimRequest(context.Background(), search)
+	if err != nil || req.URL.Query().Has(GeometryParam) {
+		t.Errorf("expected the outline not to be requested by default, got %v (%v)", req.URL, err)
+	}
+}
+
+func TestParseNominatimResponse(t *testing.T) {
+	locs, err := parseNominatimResponse(EndpointReverse, []byte(`{"place_id": 1, "display_name": "Brussels", "lat": "50.85", "lon": "4.35"}`))
+	if err != nil || len(locs) != 1 || locs[0].DisplayName != "Brussels" || locs[0].PlaceID != 1 {
+		t.Errorf("expected a single location, got %+v (%v)", locs, err)
+	}
+
+	locs, err = parseNominatimResponse(EndpointReverse, []byte(`{"error": "Unable to geocode"}`))
+	if err != nil || locs == nil || len(locs) != 0 {
+		t.Errorf("expected no locations, got %+v (%v)", locs, err)
+	}
+
+	locs, err = parseNominatimResponse(EndpointSearch, []byte(`[{"display_name": "Brussels"}, {"display_name": "Brussels, Wisconsin"}]`))
+	if err != nil || len(locs) != 2 {
+		t.Errorf("expected two locations, got %+v (%v)", locs, err)
+	}
+}
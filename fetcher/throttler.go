@@ -1,20 +1,56 @@
 package fetcher
 
 import (
+	"context"
+	"slices"
 	"sync"
 	"time"
 
 	location "github.com/owenfeehan/geocoding-nominatim-cache/location"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// maxBulkSkips is how many consecutive turns interactive calls may take while bulk calls wait, before a bulk call is
+// given a turn. This guarantees bulk work a share of the requests to the delegate, however busy interactive clients are.
+const maxBulkSkips = 4
+
+// ThrottleObserver is notified as calls wait to be throttled, e.g. to record metrics.
+type ThrottleObserver interface {
+	// Called when a call of the given priority starts waiting, before any other call that is already waiting may have
+	// been delegated.
+	Queued(priority Priority)
+
+	// Called when a call of the given priority stops waiting and is about to be delegated, having waited for the given
+	// duration.
+	Dequeued(priority Priority, wait time.Duration)
+}
+
 // Throttler wraps a LocationFetcher and ensures at most one request per second.
+//
+// Calls wait in a queue (or lane) for their priority, taking turns in order of priority, then in order of arrival.
 type throttler struct {
 	delegate LocationFetcher
 
 	// A minimum delay between calls to the delegate. The thread will sleep if necessary to ensure this delay.
 	minDelay time.Duration
 
-	mu       sync.Mutex
+	// Notified of waits, or nil if there is no observer.
+	observer ThrottleObserver
+
+	mu sync.Mutex
+
+	// Whether a call has the turn, i.e. is waiting for the delay or being delegated.
+	busy bool
+
+	// The calls waiting for a turn, for each priority. A turn is passed to a call by closing its channel.
+	lanes [][]chan struct{}
+
+	// How many consecutive turns interactive calls have taken while bulk calls waited.
+	bulkSkips int
+
+	// Only accessed by the call with the turn.
 	lastCall time.Time
 }
 
@@ -22,20 +58,113 @@ type throttler struct {
 //
 // the minDelay parameter is the minimum time to wait between calls to the delegate.
 func NewThrottler(delegate LocationFetcher, minDelay time.Duration) LocationFetcher {
-	return &throttler{delegate: delegate, minDelay: minDelay}
+	return NewThrottlerWithObserver(delegate, minDelay, nil)
+}
+
+// NewThrottlerWithObserver creates a new Throttler that wraps the given delegate, and notifies observer of any waits.
+//
+// the minDelay parameter is the minimum time to wait between calls to the delegate.
+func NewThrottlerWithObserver(delegate LocationFetcher, minDelay time.Duration, observer ThrottleObserver) LocationFetcher {
+	return &throttler{delegate: delegate, minDelay: minDelay, observer: observer, lanes: make([][]chan struct{}, len(Priorities))}
 }
 
 // Fetch calls the delegate's Fetch method, ensuring at most one call per second (thread-safe).
-func (t *throttler) Fetch(query string) ([]location.Location, error) {
-	t.mu.Lock()
-	now := time.Now()
-	wait := t.minDelay - now.Sub(t.lastCall)
-	if wait > 0 {
-		time.Sleep(wait)
+//
+// Calls are delegated in order of the priority of ctx (see WithPriority), then in order of arrival.
+// If ctx is cancelled while waiting, ctx's error is returned without calling the delegate.
+func (t *throttler) Fetch(ctx context.Context, req Request) ([]location.Location, error) {
+	priority := PriorityFromContext(ctx)
+	queuedAt := time.Now()
+	if t.observer != nil {
+		t.observer.Queued(priority)
+	}
+
+	_, span := otel.Tracer(instrumentationName).Start(ctx, "throttler.wait", trace.WithAttributes(attribute.String("priority", priority.String())))
+	err := t.wait(ctx, priority)
+	span.End()
+
+	if t.observer != nil {
+		t.observer.Dequeued(priority, time.Since(queuedAt))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t.delegate.Fetch(ctx, req)
+}
+
+// wait blocks until the call has a turn and the minimum delay has passed since the previous call, or ctx is cancelled.
+func (t *throttler) wait(ctx context.Context, priority Priority) error {
+	if err := t.acquire(ctx, priority); err != nil {
+		return err
+	}
+	defer t.release()
+
+	if wait := t.minDelay - time.Since(t.lastCall); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
 	t.lastCall = time.Now()
+	return nil
+}
+
+// acquire blocks until the call has the turn, or ctx is cancelled.
+func (t *throttler) acquire(ctx context.Context, priority Priority) error {
+	t.mu.Lock()
+	if !t.busy {
+		t.busy = true
+		t.mu.Unlock()
+		return nil
+	}
+	turn := make(chan struct{})
+	t.lanes[priority] = append(t.lanes[priority], turn)
 	t.mu.Unlock()
-	return t.delegate.Fetch(query)
+
+	select {
+	case <-turn:
+		return nil
+	case <-ctx.Done():
+		t.mu.Lock()
+		index := slices.Index(t.lanes[priority], turn)
+		if index >= 0 {
+			t.lanes[priority] = slices.Delete(t.lanes[priority], index, index+1)
+		}
+		t.mu.Unlock()
+
+		// The turn was passed to the call as it was cancelled, so is passed on instead
+		if index < 0 {
+			t.release()
+		}
+		return ctx.Err()
+	}
+}
+
+// release passes the turn to the next waiting call, if any.
+func (t *throttler) release() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	interactive, bulk := t.lanes[PriorityInteractive], t.lanes[PriorityBulk]
+	var next Priority
+	if len(bulk) > 0 && (len(interactive) == 0 || t.bulkSkips >= maxBulkSkips) {
+		next = PriorityBulk
+		t.bulkSkips = 0
+	} else if len(interactive) > 0 {
+		next = PriorityInteractive
+		if len(bulk) > 0 {
+			t.bulkSkips++
+		}
+	} else {
+		t.busy = false
+		return
+	}
+
+	close(t.lanes[next][0])
+	t.lanes[next] = t.lanes[next][1:]
 }
 
 // Assert implementation
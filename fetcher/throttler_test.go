@@ -1,7 +1,11 @@
 package fetcher
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -14,12 +18,12 @@ type mockFetcher struct {
 	delay time.Duration
 }
 
-func (m *mockFetcher) Fetch(query string) ([]location.Location, error) {
+func (m *mockFetcher) Fetch(_ context.Context, req Request) ([]location.Location, error) {
 	atomic.AddInt32(&m.calls, 1)
 	if m.delay > 0 {
 		time.Sleep(m.delay)
 	}
-	return []location.Location{{DisplayName: query}}, nil
+	return []location.Location{{DisplayName: req.Query()}}, nil
 }
 
 func TestThrottlerRespectsMinDelay(t *testing.T) {
@@ -27,8 +31,8 @@ func TestThrottlerRespectsMinDelay(t *testing.T) {
 	throttler := NewThrottler(mock, 200*time.Millisecond)
 
 	start := time.Now()
-	_, _ = throttler.Fetch("A")
-	_, _ = throttler.Fetch("B")
+	_, _ = throttler.Fetch(context.Background(), Search("A"))
+	_, _ = throttler.Fetch(context.Background(), Search("B"))
 
 	assertMinDuration(t, start)
 	assertCalls(t, mock, 2)
@@ -45,7 +49,7 @@ func TestThrottlerConcurrent(t *testing.T) {
 	for i := range 3 {
 		go func(idx int) {
 			query := fmt.Sprintf("A%d", idx)
-			_, _ = throttler.Fetch(query)
+			_, _ = throttler.Fetch(context.Background(), Search(query))
 			ch <- struct{}{}
 		}(i)
 	}
@@ -59,6 +63,97 @@ func TestThrottlerConcurrent(t *testing.T) {
 	assertCalls(t, mock, 3)
 }
 
+func TestThrottlerCancelled(t *testing.T) {
+	mock := &mockFetcher{}
+	throttler := NewThrottler(mock, 200*time.Millisecond)
+	_, _ = throttler.Fetch(context.Background(), Search("A"))
+
+	// The second call is cancelled before the delay has passed
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := throttler.Fetch(ctx, Search("B")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the wait to be cancelled, got %v", err)
+	}
+
+	// A cancelled call must not block later calls
+	if _, err := throttler.Fetch(context.Background(), Search("C")); err != nil {
+		t.Errorf("unexpected error after a cancelled call: %v", err)
+	}
+
+	assertCalls(t, mock, 2)
+}
+
+func TestThrottlerPriority(t *testing.T) {
+	mock := &orderFetcher{}
+	throttler := NewThrottler(mock, 50*time.Millisecond)
+	_, _ = throttler.Fetch(context.Background(), Search("A"))
+
+	// The first queued call takes the turn, waiting for the delay, while the others queue behind it
+	var wg sync.WaitGroup
+	fetch := func(ctx context.Context, query string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = throttler.Fetch(ctx, Search(query))
+		}()
+		time.Sleep(2 * time.Millisecond)
+	}
+	bulk := WithPriority(context.Background(), PriorityBulk)
+	for i := range 6 {
+		fetch(bulk, fmt.Sprintf("B%d", i))
+		fetch(context.Background(), fmt.Sprintf("I%d", i))
+	}
+	wg.Wait()
+
+	// Interactive calls go first, except that bulk calls are not starved
+	want := "A B0 I0 I1 I2 I3 B1 I4 I5 B2 B3 B4 B5"
+	if got := strings.Join(mock.calls, " "); got != want {
+		t.Errorf("expected calls in order %s, got %s", want, got)
+	}
+}
+
+func TestThrottlerCancelledWhileQueued(t *testing.T) {
+	mock := &orderFetcher{}
+	throttler := NewThrottler(mock, 100*time.Millisecond)
+	_, _ = throttler.Fetch(context.Background(), Search("A"))
+
+	done := make(chan struct{})
+	go func() {
+		_, _ = throttler.Fetch(context.Background(), Search("B"))
+		close(done)
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	// A call cancelled while queued behind B leaves its lane, and must not take a later turn
+	ctx, cancel := context.WithTimeout(WithPriority(context.Background(), PriorityBulk), 20*time.Millisecond)
+	defer cancel()
+	if _, err := throttler.Fetch(ctx, Search("C")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the wait to be cancelled, got %v", err)
+	}
+	<-done
+	if _, err := throttler.Fetch(context.Background(), Search("D")); err != nil {
+		t.Errorf("unexpected error after a cancelled call: %v", err)
+	}
+
+	if got := strings.Join(mock.calls, " "); got != "A B D" {
+		t.Errorf("expected calls A B D, got %s", got)
+	}
+}
+
+// orderFetcher records the order of calls.
+type orderFetcher struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (m *orderFetcher) Fetch(_ context.Context, req Request) ([]location.Location, error) {
+	query := req.Query()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, query)
+	return []location.Location{{DisplayName: query}}, nil
+}
+
 // Asserts the expected number of calls occurred on the mock fetcher.
 func assertCalls(t *testing.T, mock *mockFetcher, want int32) {
 	if got := atomic.LoadInt32(&mock.calls); got != want {
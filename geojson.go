@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owenfeehan/geocoding-nominatim-cache/location"
+)
+
+// geoJSONContentType is the content-type of GeoJSON.
+const geoJSONContentType = "application/geo+json"
+
+// wantsGeoJSON determines whether locations are requested as GeoJSON, by the format parameter or else the Accept
+// header.
+//
+// If the format is not supported, an error response is sent, and ok is false. As the response may depend on the
+// Accept header, caches are told to vary by it.
+func wantsGeoJSON(c *gin.Context) (geoJSON bool, ok bool) {
+	c.Writer.Header().Add("Vary", "Accept")
+	switch c.Query("format") {
+	case "geojson":
+		return true, true
+	case "json":
+		return false, true
+	case "":
+		return c.NegotiateFormat(gin.MIMEJSON, geoJSONContentType) == geoJSONContentType, true
+	}
+	c.JSON(http.StatusBadRequest, ErrorResponse{Error: "format must be json or geojson"})
+	return false, false
+}
+
+// renderGeoJSON responds with locations as a GeoJSON feature collection.
+func renderGeoJSON(c *gin.Context, locs []location.Location) {
+	c.Header("Content-Type", geoJSONContentType)
+	c.IndentedJSON(http.StatusOK, location.ToGeoJSON(locs))
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owenfeehan/geocoding-nominatim-cache/location"
+	"github.com/owenfeehan/geocoding-nominatim-cache/store"
+)
+
+func TestGeoJSON(t *testing.T) {
+	a := &app{
+		Store: store.NewMemoryStore(),
+		Fetcher: &mockFetcher{fetchFunc: func(query string) ([]location.Location, error) {
+			return []location.Location{{
+				DisplayName: "Brussels", Latitude: "50.85", Longitude: "4.35", OSMType: "relation", OSMID: 58004,
+				BoundingBox: []string{"50.79", "50.91", "4.31", "4.43"},
+			}}, nil
+		}},
+	}
+	engine := createGeoJSONEngine(a)
+
+	tests := []struct {
+		url, accept string
+		geoJSON     bool
+	}{
+		{"/locations/Brussels", "", false},
+		{"/locations/Brussels", "application/geo+json", true},
+		{"/locations/Brussels?format=geojson", "application/json", true},
+		{"/locations/Brussels?format=json", "application/geo+json", false},
+		{"/locations/osm/R58004", "application/geo+json", true},
+	}
+	for _, test := range tests {
+		recorder := serveGeoJSON(engine, test.url, test.accept)
+		if recorder.Code != http.StatusOK {
+			t.Errorf("unexpected status %d for %s: %s", recorder.Code, test.url, recorder.Body.String())
+			continue
+		}
+		if vary := recorder.Header().Get("Vary"); vary != "Accept" {
+			t.Errorf("expected Vary: Accept for %s, got %q", test.url, vary)
+		}
+		var collection location.FeatureCollection
+		isGeoJSON := recorder.Header().Get("Content-Type") == geoJSONContentType
+		if isGeoJSON != test.geoJSON {
+			t.Errorf("expected GeoJSON %v for %s (Accept %q), got content-type %s", test.geoJSON, test.url, test.accept, recorder.Header().Get("Content-Type"))
+		} else if isGeoJSON {
+			if err := json.Unmarshal(recorder.Body.Bytes(), &collection); err != nil || len(collection.Features) != 1 {
+				t.Errorf("unexpected feature collection for %s: %s", test.url, recorder.Body.String())
+			} else if feature := collection.Features[0]; feature.Geometry == nil || feature.Geometry.Type != "Point" || len(feature.BBox) != 4 {
+				t.Errorf("expected a point with a bbox for %s, got %+v", test.url, feature)
+			}
+		}
+	}
+
+	if recorder := serveGeoJSON(engine, "/locations/Brussels?format=xml", ""); recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected an unsupported format to be rejected, got %d", recorder.Code)
+	}
+}
+
+// createGeoJSONEngine routes the location endpoints of an app.
+func createGeoJSONEngine(a *app) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/locations/:place", a.ForwardGeocode)
+	engine.GET("/locations/osm/:ids", a.LookupOSM)
+	return engine
+}
+
+// serveGeoJSON requests url with an Accept header (unless empty), and records the response.
+func serveGeoJSON(engine *gin.Engine, url string, accept string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, url, nil)
+	if accept != "" {
+		request.Header.Set("Accept", accept)
+	}
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, request)
+	return recorder
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/url"
+	"strconv"
+
+	"github.com/owenfeehan/geocoding-nominatim-cache/fetcher"
+	"github.com/owenfeehan/geocoding-nominatim-cache/location"
+)
+
+// polygonThresholdParam simplifies the outline of each location, as for Nominatim.
+//
+// Outlines are cached in full, and simplified for each request, so requests with different thresholds share a cache
+// entry.
+const polygonThresholdParam = "polygon_threshold"
+
+// parseGeometryParams determines whether the outline of each location is requested, and by how much (in degrees) to
+// simplify it.
+func parseGeometryParams(params url.Values) (geometry bool, threshold float64, err error) {
+	switch params.Get(fetcher.GeometryParam) {
+	case "", "0":
+	case "1":
+		geometry = true
+	default:
+		return false, 0, errors.New("polygon_geojson must be 0 or 1")
+	}
+
+	if value := params.Get(polygonThresholdParam); value != "" {
+		threshold, err = strconv.ParseFloat(value, 64)
+		if err != nil || threshold < 0 || math.IsInf(threshold, 0) || math.IsNaN(threshold) {
+			return false, 0, errors.New("polygon_threshold must be a non-negative number of degrees")
+		}
+	}
+	return geometry, threshold, nil
+}
+
+// simplifyLocations simplifies the outline of each location by threshold, without changing locs.
+func simplifyLocations(locs []location.Location, threshold float64) []location.Location {
+	if threshold <= 0 {
+		return locs
+	}
+	simplified := make([]location.Location, len(locs))
+	for i, loc := range locs {
+		simplified[i] = loc.Simplified(threshold)
+	}
+	return simplified
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/owenfeehan/geocoding-nominatim-cache/location"
+	"github.com/owenfeehan/geocoding-nominatim-cache/store"
+)
+
+// testOutline is a square, with a point slightly off its bottom edge.
+const testOutline = `{"type":"Polygon","coordinates":[[[4.3,50.8],[4.35,50.801],[4.4,50.8],[4.4,50.9],[4.3,50.9],[4.3,50.8]]]}`
+
+func TestGeometry(t *testing.T) {
+	locStore := store.NewMemoryStore()
+	a := &app{
+		Store: locStore,
+		Fetcher: &mockFetcher{fetchFunc: func(query string) ([]location.Location, error) {
+			return []location.Location{{
+				DisplayName: "Brussels", Latitude: "50.85", Longitude: "4.35", OSMType: "relation", OSMID: 58004,
+				GeoJSON: json.RawMessage(testOutline),
+			}}, nil
+		}},
+		BatchMaxSize: 10,
+	}
+	engine := createGeoJSONEngine(a)
+
+	// The outline is simplified for the response, but cached in full, separately from the location without it
+	recorder := serveGeoJSON(engine, "/locations/Brussels?polygon_geojson=1&polygon_threshold=0.01", "")
+	var loc location.Location
+	if err := json.Unmarshal(recorder.Body.Bytes(), &loc); err != nil {
+		t.Fatalf("unexpected response %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var outline bytes.Buffer
+	if err := json.Compact(&outline, loc.GeoJSON); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"type":"Polygon","coordinates":[[[4.3,50.8],[4.4,50.8],[4.4,50.9],[4.3,50.9],[4.3,50.8]]]}`; outline.String() != want {
+		t.Errorf("expected the simplified outline %s, got %s", want, outline.String())
+	}
+	cached, err := locStore.Get(context.Background(), "search?polygon_geojson=1&q=Brussels")
+	if err != nil || len(cached) != 1 || string(cached[0].GeoJSON) != testOutline {
+		t.Errorf("expected the full outline to be cached, got %v (%v)", cached, err)
+	}
+
+	// In GeoJSON, the outline replaces the point
+	recorder = serveGeoJSON(engine, "/locations/osm/R58004?polygon_geojson=1&format=geojson", "")
+	var collection location.FeatureCollection
+	if err := json.Unmarshal(recorder.Body.Bytes(), &collection); err != nil || len(collection.Features) != 1 || collection.Features[0].Geometry.Type != "Polygon" {
+		t.Errorf("expected a polygon, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if cached, _ := locStore.Get(context.Background(), "lookup?osm_ids=R58004&polygon_geojson=1"); len(cached) != 1 {
+		t.Errorf("expected the outline to be cached by ID, got %v", cached)
+	}
+
+	for _, url := range []string{"/locations/Brussels?polygon_geojson=yes", "/locations/Brussels?polygon_threshold=-1", "/locations/osm/R58004?polygon_threshold=NaN"} {
+		if recorder := serveGeoJSON(engine, url, ""); recorder.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for %s, got %d", url, recorder.Code)
+		}
+	}
+	if recorder := serveNominatim(a.Search, "/search?q=Brussels&polygon_threshold=x"); recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid threshold, got %d", recorder.Code)
+	}
+}
+
+func TestSearchGeometry(t *testing.T) {
+	a := &app{
+		Store: store.NewMemoryStore(),
+		Fetcher: &mockFetcher{fetchFunc: func(query string) ([]location.Location, error) {
+			return []location.Location{{DisplayName: "Brussels", GeoJSON: json.RawMessage(testOutline)}}, nil
+		}},
+	}
+
+	recorder := serveNominatim(a.Search, "/search?q=Brussels&polygon_geojson=1&polygon_threshold=0.01")
+	var results []location.JSONv2
+	if err := json.Unmarshal(recorder.Body.Bytes(), &results); err != nil || len(results) != 1 || len(results[0].GeoJSON) >= len(testOutline) {
+		t.Errorf("expected a simplified outline, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owenfeehan/geocoding-nominatim-cache/fetcher"
+	"github.com/owenfeehan/geocoding-nominatim-cache/store"
+	"github.com/rs/zerolog/log"
+)
+
+// readyTimeout is the maximum time to wait for the store to respond when checking readiness or status.
+const readyTimeout = 2 * time.Second
+
+// storeSizeInterval is how often the entries in the store are counted for /status, as counting visits every key.
+const storeSizeInterval = 5 * time.Minute
+
+// Status codes reported by the /status endpoint, as used by Nominatim.
+const (
+	statusOK             = 0
+	statusDatabaseFailed = 700
+)
+
+// HealthResponse describes whether the process is alive.
+type HealthResponse struct {
+	Status string `json:"status" example:"ok"`
+}
+
+// ReadyResponse describes whether the service is ready to handle requests.
+type ReadyResponse struct {
+	Status string `json:"status" example:"ready"`
+	Error  string `json:"error,omitempty" example:"dial tcp 127.0.0.1:6379: connect: connection refused"`
+}
+
+// StatusResponse describes the status of the service, compatible with Nominatim's /status endpoint.
+type StatusResponse struct {
+	Status          int    `json:"status" example:"0"`
+	Message         string `json:"message" example:"OK"`
+	SoftwareVersion string `json:"software_version" example:"1.2.0"`
+	Backend         string `json:"backend" example:"badger"`
+	CacheSize       int64  `json:"cache_size" example:"1024"`
+	UptimeSeconds   int64  `json:"uptime" example:"3600"`
+}
+
+// Health handles the /healthz endpoint.
+//
+// @Summary      Check liveness
+// @Description  checks whether the process is alive, without checking any dependencies
+// @Produce      json
+// @Success      200  {object}  HealthResponse
+// @Router       /healthz [get]
+func (a *app) Health(c *gin.Context) {
+	c.JSON(http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+// Ready handles the /readyz endpoint.
+//
+// @Summary      Check readiness
+// @Description  checks whether the service is ready to handle requests, i.e. the location-store is reachable and geocoding has not been paused after repeated failures
+// @Produce      json
+// @Success      200  {object}  ReadyResponse
+// @Failure      503  {object}  ReadyResponse
+// @Router       /readyz [get]
+func (a *app) Ready(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readyTimeout)
+	defer cancel()
+
+	if err := a.Store.Ping(ctx); err != nil {
+		c.JSON(http.StatusServiceUnavailable, ReadyResponse{Status: "unavailable", Error: err.Error()})
+		return
+	}
+
+	if a.Breaker != nil && a.Breaker.Tripped() {
+		c.JSON(http.StatusServiceUnavailable, ReadyResponse{Status: "unavailable", Error: fetcher.ErrCircuitOpen.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ReadyResponse{Status: "ready"})
+}
+
+// Status handles the /status endpoint.
+//
+// @Summary      Report status
+// @Description  reports the status of the service, like Nominatim's /status endpoint, with the number of cached queries (as counted in the last few minutes), uptime, backend type and version. The response is plain text (OK or an error message) unless format=json.
+// @Produce      json
+// @Produce      plain
+// @Param        format  query     string  false  "text (default) or json"  Enums(text, json)
+// @Success      200  {object}  StatusResponse
+// @Failure      500  {object}  StatusResponse
+// @Router       /status [get]
+func (a *app) Status(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readyTimeout)
+	defer cancel()
+
+	response := StatusResponse{
+		Status:          statusOK,
+		Message:         "OK",
+		SoftwareVersion: version,
+		Backend:         a.Backend,
+		UptimeSeconds:   int64(time.Since(a.Started).Seconds()),
+	}
+
+	httpStatus := http.StatusOK
+	err := a.Store.Ping(ctx)
+	if err != nil {
+		response.Status = statusDatabaseFailed
+		response.Message = "Database connection failed: " + err.Error()
+		httpStatus = http.StatusInternalServerError
+	}
+	if a.CacheSize != nil {
+		response.CacheSize = a.CacheSize.Count()
+	}
+
+	if c.Query("format") == "json" {
+		c.JSON(httpStatus, response)
+	} else if err != nil {
+		c.String(httpStatus, "ERROR: %s", response.Message)
+	} else {
+		c.String(httpStatus, response.Message)
+	}
+}
+
+// storeSize counts the entries in a store periodically in the background, so /status reports the latest count
+// immediately, rather than counting on every request.
+type storeSize struct {
+	store store.LocationStore
+	count atomic.Int64
+}
+
+// newStoreSize creates a storeSize for a store, whose count is zero until Run first counts its entries.
+func newStoreSize(locStore store.LocationStore) *storeSize {
+	return &storeSize{store: locStore}
+}
+
+// Count returns the number of entries in the store, when last counted.
+func (s *storeSize) Count() int64 {
+	return s.count.Load()
+}
+
+// Run counts the entries in the store immediately, and then after every storeSizeInterval, until ctx is cancelled.
+func (s *storeSize) Run(ctx context.Context) {
+	ticker := time.NewTicker(storeSizeInterval)
+	defer ticker.Stop()
+	for {
+		s.recount(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// recount counts the entries in the store, keeping the previous count if this fails.
+func (s *storeSize) recount(ctx context.Context) {
+	count, err := s.store.Count(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Warn().Err(err).Msg("Failed to count the entries in the location-store")
+		}
+		return
+	}
+	s.count.Store(count)
+}
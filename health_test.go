@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owenfeehan/geocoding-nominatim-cache/location"
+	"github.com/owenfeehan/geocoding-nominatim-cache/store"
+)
+
+func TestReady(t *testing.T) {
+	a := &app{Store: &mockStore{}}
+	if got := serveHandler(a.Ready); got.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, got.Code)
+	}
+
+	a = &app{Store: &mockStore{pingFunc: func(context.Context) error { return errors.New("connection refused") }}}
+	if got := serveHandler(a.Ready); got.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, got.Code)
+	}
+}
+
+func TestReadyTripped(t *testing.T) {
+	a := &app{Store: &mockStore{}, Breaker: trippedBreaker{}}
+	if got := serveHandler(a.Ready); got.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, got.Code)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	locStore := store.NewMemoryStore()
+	if err := locStore.Set(context.Background(), locStore.BuildKey(testQuery), []location.Location{{DisplayName: testQuery}}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	a := &app{Store: locStore, Backend: "memory", Started: time.Now().Add(-time.Minute), CacheSize: newStoreSize(locStore)}
+	a.CacheSize.recount(context.Background())
+
+	got := serveHandlerWithURL(a.Status, "/status?format=json")
+	var response StatusResponse
+	if err := json.Unmarshal(got.Body.Bytes(), &response); err != nil {
+		t.Fatalf("cannot parse response: %v", err)
+	}
+	if got.Code != http.StatusOK || response.Status != statusOK || response.CacheSize != 1 || response.Backend != "memory" || response.UptimeSeconds < 60 {
+		t.Errorf("unexpected status response %d: %+v", got.Code, response)
+	}
+
+	// Plain-text by default, as for Nominatim
+	if got := serveHandlerWithURL(a.Status, "/status"); got.Body.String() != "OK" {
+		t.Errorf("expected OK, got %s", got.Body.String())
+	}
+}
+
+func TestStatusCountTimeout(t *testing.T) {
+	// A count that fails (e.g. timing out on a large database) keeps the previous count, and is not a database failure
+	a := &app{Store: &mockStore{countFunc: func(context.Context) (int64, error) { return 0, context.DeadlineExceeded }}}
+	a.CacheSize = newStoreSize(a.Store)
+	a.CacheSize.count.Store(5)
+	a.CacheSize.recount(context.Background())
+
+	got := serveHandlerWithURL(a.Status, "/status?format=json")
+	var response StatusResponse
+	if err := json.Unmarshal(got.Body.Bytes(), &response); err != nil {
+		t.Fatalf("cannot parse response: %v", err)
+	}
+	if got.Code != http.StatusOK || response.Status != statusOK || response.CacheSize != 5 {
+		t.Errorf("unexpected status response %d: %+v", got.Code, response)
+	}
+}
+
+// trippedBreaker is a fetcher.Tripper that has always tripped.
+type trippedBreaker struct{}
+
+func (trippedBreaker) Tripped() bool { return true }
+
+// serveHandler calls a handler with a GET request, and records the response.
+func serveHandler(handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	return serveHandlerWithURL(handler, "/")
+}
+
+// serveHandlerWithURL calls a handler with a GET request for the given URL, and records the response.
+func serveHandlerWithURL(handler gin.HandlerFunc, url string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, url, nil)
+	handler(c)
+	return recorder
+}
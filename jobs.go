@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/owenfeehan/geocoding-nominatim-cache/fetcher"
+	"github.com/owenfeehan/geocoding-nominatim-cache/jobs"
+	"github.com/owenfeehan/geocoding-nominatim-cache/location"
+	"github.com/owenfeehan/geocoding-nominatim-cache/router"
+)
+
+// SubmitJob handles the /jobs endpoint.
+//
+// @Summary      Submit a job to geocode many placenames
+// @Description  submits a JSON array (or NDJSON stream) of placename-query-strings, to be geocoded in the background. Jobs are processed one at a time, in order of submission, and survive restarts of the service.
+// @Accept       json
+// @Accept       application/x-ndjson
+// @Produce      json
+// @Param        queries  body      []string  true  "queries indicating places or addresses"
+// @Success      202  {object}  jobs.Job
+// @Header       202  {string}  Location      "the URL of the job"
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse  "missing or invalid API key (only when API keys are required)"
+// @Failure      413  {object}  ErrorResponse  "the job contains too many queries"
+// @Failure      429  {object}  ErrorResponse  "a rate-limit or quota of the client is exceeded"
+// @Failure      500  {object}  ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /jobs [post]
+func (a *app) SubmitJob(c *gin.Context) {
+	queries, err := readBatch(c.Request.Body, c.ContentType() == ndjsonContentType, a.JobMaxSize)
+	if errors.Is(err, errBatchTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{Error: fmt.Sprintf("a job may contain at most %d queries", a.JobMaxSize)})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	} else if len(queries) == 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "a job requires at least one query"})
+		return
+	}
+
+	job, err := a.Jobs.Submit(c.Request.Context(), queries, jobSubmitter(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.Header("Location", "/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetJob handles the /jobs/:id endpoint.
+//
+// @Summary      Report the progress of a job
+// @Description  reports how many queries of a job have been processed, and (while unfinished) an estimate of when it will complete, assuming every remaining query must be fetched from Nominatim
+// @Produce      json
+// @Param        id   path      string  true  "the ID of the job"
+// @Success      200  {object}  jobs.Job
+// @Failure      401  {object}  ErrorResponse  "missing or invalid API key (only when API keys are required)"
+// @Failure      404  {object}  ErrorResponse  "the job does not exist, or was submitted with another API key"
+// @Failure      500  {object}  ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /jobs/{id} [get]
+func (a *app) GetJob(c *gin.Context) {
+	job, err := a.getJob(c, c.Param("id"))
+	if errors.Is(err, jobs.ErrNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// JobResults handles the /jobs/:id/results endpoint.
+//
+// @Summary      Stream the results of a job
+// @Description  streams a result (with a location or an error) for each query of a job processed so far, in order, as NDJSON. Results may be retrieved incrementally with offset.
+// @Produce      application/x-ndjson
+// @Param        id      path      string  true   "the ID of the job"
+// @Param        offset  query     int     false  "the index of the first result to return"
+// @Success      200  {array}   jobs.Result
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse  "missing or invalid API key (only when API keys are required)"
+// @Failure      404  {object}  ErrorResponse  "the job does not exist, or was submitted with another API key"
+// @Failure      500  {object}  ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /jobs/{id}/results [get]
+func (a *app) JobResults(c *gin.Context) {
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "offset must be a non-negative integer"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if _, err := a.getJob(c, c.Param("id")); errors.Is(err, jobs.ErrNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	// Once streaming has begun, errors can no longer be reported with a status, so the stream is just cut short
+	c.Header("Content-Type", ndjsonContentType)
+	c.Status(http.StatusOK)
+	encoder := json.NewEncoder(c.Writer)
+	err = a.Jobs.Results(ctx, c.Param("id"), offset, func(result jobs.Result) error {
+		return encoder.Encode(result)
+	})
+	if err != nil {
+		_ = c.Error(err)
+	}
+}
+
+// heartbeatInterval is how often a heartbeat event is sent on an event stream, so clients (and any proxies) can tell
+// an idle stream from a broken one.
+var heartbeatInterval = 15 * time.Second
+
+// JobEvents handles the /jobs/:id/events endpoint.
+//
+// @Summary      Stream the results of a job as Server-Sent Events
+// @Description  streams a result event for each query of a job as soon as it is processed, whose ID is the index of the result, until the job finishes with a done event. Heartbeat events report the progress of the job periodically. A reconnecting client resumes after the result in Last-Event-ID (or from offset).
+// @Produce      text/event-stream
+// @Param        id             path      string  true   "the ID of the job"
+// @Param        offset         query     int     false  "the index of the first result to stream"
+// @Param        Last-Event-ID  header    int     false  "the index of the last result received, to resume after"
+// @Success      200  {array}   jobs.Result
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse  "missing or invalid API key (only when API keys are required)"
+// @Failure      404  {object}  ErrorResponse  "the job does not exist, or was submitted with another API key"
+// @Failure      500  {object}  ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /jobs/{id}/events [get]
+func (a *app) JobEvents(c *gin.Context) {
+	next, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if lastEventID := c.GetHeader("Last-Event-ID"); lastEventID != "" {
+		next, err = strconv.Atoi(lastEventID)
+		next++
+	}
+	if err != nil || next < 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "offset and Last-Event-ID must be non-negative integers"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	if _, err := a.getJob(c, id); errors.Is(err, jobs.ErrNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	// Once streaming has begun, errors can no longer be reported with a status, so the stream is just cut short
+	for {
+		// Taken before the job is read, so no progress in between is missed
+		progress := a.Jobs.Progress(id)
+
+		job, err := a.Jobs.Get(ctx, id)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+		err = a.Jobs.Results(ctx, id, next, func(result jobs.Result) error {
+			c.Render(-1, sse.Event{Id: strconv.Itoa(result.Index), Event: "result", Data: result})
+			next = result.Index + 1
+			return nil
+		})
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+		if job.Finished() && next >= job.Completed {
+			c.SSEvent("done", job)
+			return
+		}
+		c.Writer.Flush()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-router.ShuttingDown(ctx):
+			// A reconnecting client resumes after the last result, from another replica or once restarted
+			return
+		case <-progress:
+		case <-heartbeat.C:
+			c.SSEvent("heartbeat", job)
+			c.Writer.Flush()
+		}
+	}
+}
+
+// getJob retrieves a job, which is not found if submitted with another API key than that of the request (if any).
+func (a *app) getJob(c *gin.Context, id string) (*jobs.Job, error) {
+	return a.Jobs.GetForKey(c.Request.Context(), id, router.RequestClient(c).KeyID)
+}
+
+// resolveJobQuery geocodes a query of a job, with the bulk priority, and within the limits of its submitter.
+//
+// Errors that may not recur later, e.g. while a limit is exceeded or Nominatim is unavailable, are returned as a
+// jobs.RetryError.
+func (a *app) resolveJobQuery(ctx context.Context, submitter *jobs.Submitter, query string) (location.Location, error) {
+	if submitter != nil && a.Limiters != nil {
+		ctx = a.Limiters.WithClient(ctx, jobClient(submitter))
+	}
+	loc, err := queryLocation(fetcher.WithPriority(ctx, fetcher.PriorityBulk), a.Store, a.Fetcher, fetcher.Search(query))
+	if err == nil || errors.Is(err, errNoLocations) {
+		return loc, err
+	}
+
+	var limitErr *router.LimitError
+	var openErr *fetcher.CircuitOpenError
+	var statusErr *fetcher.StatusError
+	if errors.As(err, &limitErr) {
+		// Wait until the submitter may make another request
+		return loc, &jobs.RetryError{Err: err, After: limitErr.RetryAfter}
+	} else if errors.As(err, &openErr) {
+		// Wait for the cooldown, or else for the trial call of another request
+		return loc, &jobs.RetryError{Err: err, After: max(openErr.RetryAfter, time.Second)}
+	} else if errors.As(err, &statusErr) && statusErr.StatusCode != http.StatusTooManyRequests && statusErr.StatusCode < http.StatusInternalServerError {
+		// Nominatim rejected the query itself
+		return loc, err
+	}
+
+	// Otherwise, Nominatim or the store may be unavailable for now
+	return loc, &jobs.RetryError{Err: err}
+}
+
+// jobSubmitter identifies the client submitting a job, after any authentication.
+func jobSubmitter(c *gin.Context) *jobs.Submitter {
+	client := router.RequestClient(c)
+	submitter := &jobs.Submitter{IP: client.IP, KeyID: client.KeyID}
+	if client.Key != nil {
+		submitter.KeyName = client.Key.Name
+		submitter.MissesPerMinute = client.Key.MissesPerMinute
+		submitter.DailyMisses = client.Key.DailyMisses
+	}
+	return submitter
+}
+
+// jobClient is the client on whose behalf the queries of a job are resolved.
+func jobClient(submitter *jobs.Submitter) router.Client {
+	client := router.Client{IP: submitter.IP, KeyID: submitter.KeyID}
+	if submitter.KeyID != "" {
+		client.Key = &router.APIKey{Name: submitter.KeyName, MissesPerMinute: submitter.MissesPerMinute, DailyMisses: submitter.DailyMisses}
+	}
+	return client
+}
@@ -0,0 +1,846 @@
+// Package jobs geocodes large batches of queries asynchronously, as jobs that are processed in the background.
+//
+// The state of each job, including its queries and results, is persisted as records in the store, so jobs survive
+// restarts of the service and resume where they left off.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/owenfeehan/geocoding-nominatim-cache/location"
+	"github.com/owenfeehan/geocoding-nominatim-cache/store"
+	"github.com/rs/zerolog/log"
+)
+
+// Kinds of the records that hold the state of jobs.
+const (
+	jobKind     = "job"
+	queriesKind = "job-queries"
+
+	// Followed by the job ID, so the results of a job can be iterated (and deleted) together.
+	resultsKindPrefix = "job-results:"
+
+	// The lease on processing jobs, held by a single manager among those sharing the store.
+	leaseKind = "job-lease"
+	leaseID   = "runner"
+)
+
+// sweepInterval is how often jobs are checked for deletion, after their retention period.
+const sweepInterval = time.Hour
+
+// leaseDuration is how long the lease on processing jobs is held without being renewed, before another manager may
+// acquire it. The lease is renewed (and other managers check the store for new jobs) three times as often.
+var leaseDuration = 30 * time.Second
+
+// pollInterval is how often the progress of a job is read from the store, while another manager processes jobs.
+var pollInterval = time.Second
+
+// ErrNotFound is returned when a job does not exist (or has been deleted after its retention period).
+var ErrNotFound = errors.New("job not found")
+
+// Delays before retrying a query after a RetryError without a delay of its own, doubling with each attempt.
+var (
+	retryBaseDelay = time.Second
+	retryMaxDelay  = 5 * time.Minute
+)
+
+// maxAttempts is how many times a query is attempted after RetryErrors without a delay, before it fails.
+const maxAttempts = 10
+
+// RetryError is returned by a Resolver when a query may succeed later, e.g. when Nominatim is unavailable or a limit is
+// exceeded, so it is retried rather than failing.
+type RetryError struct {
+	Err error
+
+	// How long to wait before retrying, e.g. until a limit allows another request, which may be repeated indefinitely.
+	// If zero, the delay grows with each attempt, until the query fails after maxAttempts.
+	After time.Duration
+}
+
+func (e *RetryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// Status describes the stage of a job.
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusCompleted Status = "completed"
+	StatusFailed    Status = "failed"
+)
+
+// Job describes a job and its progress.
+type Job struct {
+	ID     string `json:"id" example:"9f86d081884c7d65"`
+	Status Status `json:"status" example:"running"`
+
+	// Describes why the job failed, when the status is failed.
+	Error string `json:"error,omitempty"`
+
+	// The number of queries in the job, and how many have been processed (including any that failed).
+	Total     int `json:"total" example:"10000"`
+	Completed int `json:"completed" example:"2500"`
+	Failed    int `json:"failed" example:"12"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	// When the job is expected to complete, if every remaining query (including those of jobs ahead of it) must be
+	// fetched from Nominatim. Cached queries are faster, so this is an upper bound.
+	EstimatedCompletion *time.Time `json:"estimated_completion,omitempty"`
+
+	// Who submitted the job, which is stored but not reported.
+	Submitter *Submitter `json:"submitter,omitempty" swaggerignore:"true"`
+}
+
+// Submitter identifies who submitted a job, so the job's requests to Nominatim count against the same limits as their
+// own requests.
+type Submitter struct {
+	IP string `json:"ip,omitempty"`
+
+	// The API key they presented (if any), by the hash of its secret, with its name and limits when submitted.
+	KeyID           string `json:"key_id,omitempty"`
+	KeyName         string `json:"key_name,omitempty"`
+	MissesPerMinute int    `json:"misses_per_minute,omitempty"`
+	DailyMisses     int    `json:"daily_misses,omitempty"`
+}
+
+// Finished indicates whether the job will not be processed any further.
+func (j *Job) Finished() bool {
+	return j.Status == StatusCompleted || j.Status == StatusFailed
+}
+
+// Result is the result of a single query in a job.
+type Result struct {
+	// The position of the query in the job.
+	Index    int                `json:"index" example:"0"`
+	Query    string             `json:"query" example:"Brussels"`
+	Location *location.Location `json:"location,omitempty"`
+	Error    string             `json:"error,omitempty" example:"no locations found for query: Atlantis"`
+}
+
+// Resolver geocodes a single query of a job (using the cache if possible), on behalf of its submitter (if known).
+//
+// It returns a RetryError if the query may succeed later, and any other error if the query cannot succeed.
+type Resolver func(ctx context.Context, submitter *Submitter, query string) (location.Location, error)
+
+// Manager submits jobs, and processes them one at a time in order of submission.
+//
+// It is safe for concurrent use.
+type Manager struct {
+	records store.RecordStore
+	resolve Resolver
+
+	// The minimum interval between requests to Nominatim, from which completion is estimated.
+	interval time.Duration
+
+	// How long to keep finished jobs, before deleting them. If zero, they are kept forever.
+	retention time.Duration
+
+	mu sync.Mutex
+
+	// The IDs of unfinished jobs, in the order they are processed, and how many queries remain in each.
+	queue     []string
+	remaining map[string]int
+
+	// Signals the worker that a job has been submitted.
+	wake chan struct{}
+
+	// Whether the manager holds the lease on processing jobs, so knows when each job makes progress.
+	leased bool
+
+	// For each job being waited on, closed (and removed) when the job makes progress.
+	progress map[string]chan struct{}
+}
+
+// NewManager creates a manager that keeps the state of jobs in records, and resolves their queries with resolve.
+//
+// interval is the minimum interval between requests to Nominatim (used to estimate completion), and retention is how
+// long to keep finished jobs (or zero to keep them forever).
+//
+// Jobs are only processed while Run is running, by whichever of the managers sharing records holds the lease.
+func NewManager(records store.RecordStore, resolve Resolver, interval time.Duration, retention time.Duration) *Manager {
+	return &Manager{
+		records:   records,
+		resolve:   resolve,
+		interval:  interval,
+		retention: retention,
+		remaining: make(map[string]int),
+		wake:      make(chan struct{}, 1),
+		progress:  make(map[string]chan struct{}),
+	}
+}
+
+// Submit creates a job for the given queries, which is processed after any jobs submitted before it.
+func (m *Manager) Submit(ctx context.Context, queries []string, submitter *Submitter) (*Job, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	queriesValue, err := json.Marshal(queries)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.records.SetRecord(ctx, queriesKind, id, queriesValue); err != nil {
+		return nil, fmt.Errorf("failed to save the queries of the job: %w", err)
+	}
+
+	job := &Job{ID: id, Status: StatusQueued, Total: len(queries), CreatedAt: time.Now().UTC(), Submitter: submitter}
+	if err := m.saveJob(ctx, job); err != nil {
+		return nil, err
+	}
+
+	m.enqueue(job)
+	m.estimate(job)
+	job.Submitter = nil
+	return job, nil
+}
+
+// Get retrieves a job, with an estimated completion if unfinished, or returns ErrNotFound.
+func (m *Manager) Get(ctx context.Context, id string) (*Job, error) {
+	return m.GetForKey(ctx, id, "")
+}
+
+// GetForKey retrieves a job as Get, but returns ErrNotFound if keyID (the hash of an API key) is not empty and the job
+// was submitted with another key, so only the submitter of a job can follow it.
+func (m *Manager) GetForKey(ctx context.Context, id string, keyID string) (*Job, error) {
+	job, err := m.loadJob(ctx, id)
+	if err != nil {
+		return nil, err
+	} else if keyID != "" && (job.Submitter == nil || job.Submitter.KeyID != keyID) {
+		return nil, ErrNotFound
+	}
+	m.estimate(job)
+	job.Submitter = nil
+	return job, nil
+}
+
+// Results calls fn for each result of a job, in order, from the result with index from.
+//
+// Only the results of queries processed when Results is called are visited.
+func (m *Manager) Results(ctx context.Context, id string, from int, fn func(Result) error) error {
+	job, err := m.loadJob(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	for index := max(from, 0); index < job.Completed; index++ {
+		result, err := m.loadResult(ctx, id, index)
+		if err != nil {
+			return err
+		}
+		if err := fn(result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Progress returns a channel that is closed when a job next makes progress (or finishes).
+//
+// While another manager holds the lease on processing jobs, the progress of the job is not known, so the channel is
+// instead closed after a short interval, to read the job again from the store.
+//
+// To avoid missing progress, call Progress before reading the state of a job, and then wait on the channel.
+func (m *Manager) Progress(id string) <-chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.leased {
+		poll := make(chan struct{})
+		time.AfterFunc(pollInterval, func() { close(poll) })
+		return poll
+	}
+	progress, ok := m.progress[id]
+	if !ok {
+		progress = make(chan struct{})
+		m.progress[id] = progress
+	}
+	return progress
+}
+
+// notifyProgress closes the progress channel of a job, if it is being waited on.
+func (m *Manager) notifyProgress(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if progress, ok := m.progress[id]; ok {
+		close(progress)
+		delete(m.progress, id)
+	}
+}
+
+// setLeased records whether the manager holds the lease, closing every progress channel so those waiting on them call
+// Progress again.
+func (m *Manager) setLeased(leased bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.leased = leased
+	for id, progress := range m.progress {
+		close(progress)
+		delete(m.progress, id)
+	}
+}
+
+// Run processes jobs until ctx is cancelled, while the manager holds the lease on processing jobs.
+//
+// Only one of the managers sharing a store holds the lease at a time, so each job is processed by a single replica of
+// the service. Until it acquires the lease (e.g. when the replica holding it stops), a manager only refreshes its queue
+// from the store, so its estimates include the jobs submitted to other replicas.
+func (m *Manager) Run(ctx context.Context) {
+	owner, err := newID()
+	if err != nil {
+		log.Error().Err(err).Msg("Cannot identify the manager of jobs")
+		return
+	}
+
+	for {
+		expiresAt, err := m.acquireLease(ctx, owner)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Error().Err(err).Msg("Failed to acquire the lease on processing jobs")
+		}
+
+		if !expiresAt.IsZero() {
+			log.Info().Msg("Acquired the lease on processing jobs")
+			m.runLeased(ctx, owner, expiresAt)
+		} else if err := m.refresh(ctx); err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Msg("Failed to refresh the queue of jobs")
+		}
+
+		if !sleep(ctx, leaseDuration/3) {
+			return
+		}
+	}
+}
+
+// runLeased processes jobs until ctx is cancelled or the lease (expiring at expiresAt) is lost, first resuming any jobs
+// left unfinished (e.g. by a restart).
+//
+// Finished jobs are deleted after the retention period.
+func (m *Manager) runLeased(ctx context.Context, owner string, expiresAt time.Time) {
+	leaseCtx, cancel := context.WithCancel(ctx)
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		m.renewLease(leaseCtx, cancel, owner, expiresAt)
+	}()
+	m.setLeased(true)
+	defer func() {
+		cancel()
+		<-renewed
+		m.setLeased(false)
+		m.releaseLease(ctx, owner)
+	}()
+
+	if err := m.refresh(leaseCtx); err != nil && leaseCtx.Err() == nil {
+		log.Error().Err(err).Msg("Failed to resume unfinished jobs")
+	}
+
+	sweep := time.NewTicker(sweepInterval)
+	defer sweep.Stop()
+	poll := time.NewTicker(leaseDuration / 3)
+	defer poll.Stop()
+	m.deleteExpired(leaseCtx)
+
+	for {
+		if id, ok := m.next(); ok {
+			m.process(leaseCtx, id)
+		} else {
+			select {
+			case <-leaseCtx.Done():
+				return
+			case <-m.wake:
+			case <-poll.C:
+				// Finds jobs submitted to other replicas
+				if err := m.refresh(leaseCtx); err != nil && leaseCtx.Err() == nil {
+					log.Error().Err(err).Msg("Failed to refresh the queue of jobs")
+				}
+			case <-sweep.C:
+				m.deleteExpired(leaseCtx)
+			}
+		}
+
+		if leaseCtx.Err() != nil {
+			return
+		}
+	}
+}
+
+// jobLease is the record of the lease on processing jobs.
+type jobLease struct {
+	Owner     string    `json:"owner"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// acquireLease acquires (or renews) the lease on processing jobs for owner, returning when it expires, or the zero time
+// if another owner holds it.
+func (m *Manager) acquireLease(ctx context.Context, owner string) (time.Time, error) {
+	current, err := m.records.GetRecord(ctx, leaseKind, leaseID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read the lease: %w", err)
+	}
+	if current != nil {
+		var lease jobLease
+		if err := json.Unmarshal(current, &lease); err != nil {
+			return time.Time{}, fmt.Errorf("cannot parse the lease: %w", err)
+		} else if lease.Owner != owner && time.Now().Before(lease.ExpiresAt) {
+			return time.Time{}, nil
+		}
+	}
+
+	expiresAt := time.Now().Add(leaseDuration)
+	value, err := json.Marshal(jobLease{Owner: owner, ExpiresAt: expiresAt.UTC()})
+	if err != nil {
+		return time.Time{}, err
+	}
+	// Another manager may have acquired the lease since it was read
+	if swapped, err := m.records.SwapRecord(ctx, leaseKind, leaseID, current, value); err != nil {
+		return time.Time{}, fmt.Errorf("failed to save the lease: %w", err)
+	} else if !swapped {
+		return time.Time{}, nil
+	}
+	return expiresAt, nil
+}
+
+// renewLease renews the lease on processing jobs until ctx is cancelled, or calls cancel if the lease is lost.
+//
+// Failures to renew are tolerated while the lease (expiring at expiresAt) would remain held until the next renewal.
+func (m *Manager) renewLease(ctx context.Context, cancel context.CancelFunc, owner string, expiresAt time.Time) {
+	defer cancel()
+	interval := leaseDuration / 3
+	renew := time.NewTicker(interval)
+	defer renew.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-renew.C:
+		}
+
+		renewed, err := m.acquireLease(ctx, owner)
+		if !renewed.IsZero() {
+			expiresAt = renewed
+			continue
+		} else if ctx.Err() != nil {
+			return
+		} else if err != nil && time.Now().Add(interval).Before(expiresAt) {
+			log.Warn().Err(err).Msg("Failed to renew the lease on processing jobs")
+			continue
+		}
+		log.Warn().Err(err).Msg("Lost the lease on processing jobs")
+		return
+	}
+}
+
+// releaseLease expires the lease on processing jobs, if still held by owner, so another manager may acquire it without
+// waiting (e.g. when a replica is stopped).
+func (m *Manager) releaseLease(ctx context.Context, owner string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	current, err := m.records.GetRecord(ctx, leaseKind, leaseID)
+	var lease jobLease
+	if err != nil || current == nil || json.Unmarshal(current, &lease) != nil || lease.Owner != owner {
+		return
+	}
+	value, err := json.Marshal(jobLease{Owner: owner})
+	if err == nil {
+		_, err = m.records.SwapRecord(ctx, leaseKind, leaseID, current, value)
+	}
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to release the lease on processing jobs")
+	}
+}
+
+// refresh queues every unfinished job (in the order they were created) that is not already queued, and removes jobs
+// that have finished since they were queued (e.g. by another replica).
+func (m *Manager) refresh(ctx context.Context) error {
+	var unfinished []*Job
+	finished := make(map[string]bool)
+	err := m.records.ForEachRecord(ctx, jobKind, func(id string, value []byte) error {
+		var job Job
+		if err := json.Unmarshal(value, &job); err != nil {
+			return fmt.Errorf("cannot parse job record: %w", err)
+		}
+		if job.Finished() {
+			finished[id] = true
+		} else {
+			unfinished = append(unfinished, &job)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(unfinished, func(i, j int) bool {
+		return unfinished[i].CreatedAt.Before(unfinished[j].CreatedAt)
+	})
+	for _, job := range unfinished {
+		if m.enqueue(job) {
+			log.Info().Str("job", job.ID).Int("completed", job.Completed).Int("total", job.Total).Msg("Queued job")
+		} else {
+			m.setRemaining(job.ID, job.Total-job.Completed)
+		}
+	}
+	for _, id := range m.queued() {
+		if finished[id] {
+			m.dequeue(id)
+		}
+	}
+	return nil
+}
+
+// process resolves the remaining queries of a job, saving each result and the progress as it goes.
+//
+// If ctx is cancelled, the job is left unfinished, to be resumed later.
+func (m *Manager) process(ctx context.Context, id string) {
+	job, queries, err := m.loadJobAndQueries(ctx, id)
+	if err != nil {
+		m.fail(ctx, id, job, err)
+		return
+	} else if job.Finished() {
+		// Finished by another replica, while it held the lease
+		m.dequeue(id)
+		return
+	}
+
+	if job.Status == StatusQueued {
+		now := time.Now().UTC()
+		job.Status = StatusRunning
+		job.StartedAt = &now
+		if err := m.saveJob(ctx, job); err != nil {
+			m.fail(ctx, id, job, err)
+			return
+		}
+	}
+
+	attempts := 0
+	for job.Completed < job.Total {
+		index := job.Completed
+		result := Result{Index: index, Query: queries[index]}
+		loc, err := m.resolve(ctx, job.Submitter, result.Query)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// The same query is retried after a delay, unless it has failed too many times
+		var retryErr *RetryError
+		if errors.As(err, &retryErr) && (retryErr.After > 0 || attempts+1 < maxAttempts) {
+			delay := retryErr.After
+			if delay <= 0 {
+				delay = min(retryBaseDelay<<attempts, retryMaxDelay)
+				attempts++
+			}
+			log.Warn().Err(err).Str("job", id).Int("index", index).Dur("delay", delay).Msg("Retrying a query of the job")
+			if !sleep(ctx, delay) {
+				return
+			}
+			continue
+		}
+		attempts = 0
+
+		if err != nil {
+			result.Error = err.Error()
+			job.Failed++
+		} else {
+			result.Location = &loc
+		}
+
+		if err := m.saveResult(ctx, id, result); err != nil {
+			m.fail(ctx, id, job, err)
+			return
+		}
+		job.Completed++
+		if err := m.saveJob(ctx, job); err != nil {
+			m.fail(ctx, id, job, err)
+			return
+		}
+		m.setRemaining(id, job.Total-job.Completed)
+		m.notifyProgress(id)
+	}
+
+	now := time.Now().UTC()
+	job.Status = StatusCompleted
+	job.FinishedAt = &now
+	if err := m.saveJob(ctx, job); err != nil {
+		log.Error().Err(err).Str("job", id).Msg("Failed to save the completed job")
+	}
+	m.dequeue(id)
+	m.notifyProgress(id)
+}
+
+// sleep waits for a delay, returning false if ctx is cancelled first.
+func sleep(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// fail marks a job as failed (e.g. when its state cannot be read or written), so it is not processed further.
+func (m *Manager) fail(ctx context.Context, id string, job *Job, err error) {
+	log.Error().Err(err).Str("job", id).Msg("Job failed")
+	m.dequeue(id)
+
+	if job == nil || ctx.Err() != nil {
+		return
+	}
+	now := time.Now().UTC()
+	job.Status = StatusFailed
+	job.Error = err.Error()
+	job.FinishedAt = &now
+	if err := m.saveJob(ctx, job); err != nil {
+		log.Error().Err(err).Str("job", id).Msg("Failed to save the failed job")
+	}
+	m.notifyProgress(id)
+}
+
+// deleteExpired deletes the records of jobs that finished longer ago than the retention period.
+func (m *Manager) deleteExpired(ctx context.Context) {
+	if m.retention <= 0 {
+		return
+	}
+
+	cutoff := time.Now().Add(-m.retention)
+	var expired []string
+	err := m.records.ForEachRecord(ctx, jobKind, func(id string, value []byte) error {
+		var job Job
+		if err := json.Unmarshal(value, &job); err == nil && job.FinishedAt != nil && job.FinishedAt.Before(cutoff) {
+			expired = append(expired, id)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to find expired jobs")
+		return
+	}
+
+	for _, id := range expired {
+		if err := m.deleteJob(ctx, id); err != nil {
+			log.Error().Err(err).Str("job", id).Msg("Failed to delete expired job")
+		}
+	}
+}
+
+// deleteJob deletes all the records of a job, with the job record itself last.
+func (m *Manager) deleteJob(ctx context.Context, id string) error {
+	var indices []string
+	err := m.records.ForEachRecord(ctx, resultsKindPrefix+id, func(index string, _ []byte) error {
+		indices = append(indices, index)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, index := range indices {
+		if err := m.records.DeleteRecord(ctx, resultsKindPrefix+id, index); err != nil {
+			return err
+		}
+	}
+	if err := m.records.DeleteRecord(ctx, queriesKind, id); err != nil {
+		return err
+	}
+	return m.records.DeleteRecord(ctx, jobKind, id)
+}
+
+// enqueue adds an unfinished job to the end of the queue, and wakes the worker, returning false if already queued.
+func (m *Manager) enqueue(job *Job) bool {
+	m.mu.Lock()
+	// A job submitted while refreshing may be found by both
+	_, queued := m.remaining[job.ID]
+	if !queued {
+		m.queue = append(m.queue, job.ID)
+		m.remaining[job.ID] = job.Total - job.Completed
+	}
+	m.mu.Unlock()
+
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+	return !queued
+}
+
+// queued returns the IDs of the queued jobs.
+func (m *Manager) queued() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.queue...)
+}
+
+// next returns the job at the front of the queue, if any.
+func (m *Manager) next() (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.queue) == 0 {
+		return "", false
+	}
+	return m.queue[0], true
+}
+
+// dequeue removes a finished job from the queue.
+func (m *Manager) dequeue(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, queued := range m.queue {
+		if queued == id {
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			break
+		}
+	}
+	delete(m.remaining, id)
+}
+
+// setRemaining records how many queries of a job remain to be processed.
+func (m *Manager) setRemaining(id string, remaining int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remaining[id] = remaining
+}
+
+// estimate sets the estimated completion of an unfinished job, from the queries remaining in it and the jobs ahead of it.
+func (m *Manager) estimate(job *Job) {
+	if job.Finished() {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	remaining := 0
+	for _, id := range m.queue {
+		remaining += m.remaining[id]
+		if id == job.ID {
+			break
+		}
+	}
+	estimate := time.Now().UTC().Add(time.Duration(remaining) * m.interval)
+	job.EstimatedCompletion = &estimate
+}
+
+// loadJob reads the record of a job, or returns ErrNotFound.
+func (m *Manager) loadJob(ctx context.Context, id string) (*Job, error) {
+	value, err := m.records.GetRecord(ctx, jobKind, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the job: %w", err)
+	} else if value == nil {
+		return nil, ErrNotFound
+	}
+
+	var job Job
+	if err := json.Unmarshal(value, &job); err != nil {
+		return nil, fmt.Errorf("cannot parse job record: %w", err)
+	}
+	return &job, nil
+}
+
+// loadJobAndQueries reads the record of a job and its queries.
+func (m *Manager) loadJobAndQueries(ctx context.Context, id string) (*Job, []string, error) {
+	job, err := m.loadJob(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	value, err := m.records.GetRecord(ctx, queriesKind, id)
+	if err != nil {
+		return job, nil, fmt.Errorf("failed to read the queries of the job: %w", err)
+	}
+	var queries []string
+	if err := json.Unmarshal(value, &queries); err != nil {
+		return job, nil, fmt.Errorf("cannot parse the queries of the job: %w", err)
+	} else if len(queries) != job.Total {
+		return job, nil, fmt.Errorf("expected %d queries for the job, but found %d", job.Total, len(queries))
+	}
+	return job, queries, nil
+}
+
+// saveJob writes the record of a job.
+func (m *Manager) saveJob(ctx context.Context, job *Job) error {
+	stored := *job
+	stored.EstimatedCompletion = nil
+	value, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	if err := m.records.SetRecord(ctx, jobKind, job.ID, value); err != nil {
+		return fmt.Errorf("failed to save the job: %w", err)
+	}
+	return nil
+}
+
+// loadResult reads the result of a single query of a job.
+func (m *Manager) loadResult(ctx context.Context, id string, index int) (Result, error) {
+	var result Result
+	value, err := m.records.GetRecord(ctx, resultsKindPrefix+id, resultID(index))
+	if err != nil {
+		return result, fmt.Errorf("failed to read result %d of the job: %w", index, err)
+	} else if value == nil {
+		return result, fmt.Errorf("result %d of the job is missing", index)
+	}
+	if err := json.Unmarshal(value, &result); err != nil {
+		return result, fmt.Errorf("cannot parse result %d of the job: %w", index, err)
+	}
+	return result, nil
+}
+
+// saveResult writes the result of a single query of a job.
+func (m *Manager) saveResult(ctx context.Context, id string, result Result) error {
+	value, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	if err := m.records.SetRecord(ctx, resultsKindPrefix+id, resultID(result.Index), value); err != nil {
+		return fmt.Errorf("failed to save result %d of the job: %w", result.Index, err)
+	}
+	return nil
+}
+
+// RecordKinds lists the kinds of the records holding the state of jobs, e.g. to copy them to another store.
+//
+// The lease on processing jobs is excluded, as it only concerns the managers sharing a store.
+func RecordKinds(ctx context.Context, records store.RecordStore) ([]string, error) {
+	kinds := []string{jobKind, queriesKind}
+	err := records.ForEachRecord(ctx, jobKind, func(id string, _ []byte) error {
+		kinds = append(kinds, resultsKindPrefix+id)
+		return nil
+	})
+	return kinds, err
+}
+
+// resultID identifies the record of a result, padded so records are ordered by index in stores that sort keys.
+func resultID(index int) string {
+	return fmt.Sprintf("%010d", index)
+}
+
+// newID generates a random identifier for a job, which is impractical to guess.
+func newID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
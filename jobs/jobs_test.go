@@ -0,0 +1,319 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/owenfeehan/geocoding-nominatim-cache/location"
+	"github.com/owenfeehan/geocoding-nominatim-cache/store"
+)
+
+func TestManagerProcessesJobs(t *testing.T) {
+	records := store.NewMemoryStore().(store.RecordStore)
+	manager := NewManager(records, resolveTest, time.Second, 0)
+
+	ctx := context.Background()
+	job, err := manager.Submit(ctx, []string{"Paris", "Atlantis", "Berlin"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != StatusQueued || job.Total != 3 {
+		t.Errorf("unexpected job %+v", job)
+	}
+	if job.EstimatedCompletion == nil || time.Until(*job.EstimatedCompletion) < 2*time.Second {
+		t.Errorf("expected completion to be estimated from the interval, got %v", job.EstimatedCompletion)
+	}
+
+	stop := runManager(manager)
+	defer stop()
+	job = waitForJob(t, manager, job.ID)
+
+	if job.Completed != 3 || job.Failed != 1 || job.StartedAt == nil || job.FinishedAt == nil || job.EstimatedCompletion != nil {
+		t.Errorf("unexpected completed job %+v", job)
+	}
+
+	results := collectResults(t, manager, job.ID, 1)
+	if len(results) != 2 || results[0].Query != "Atlantis" || results[0].Error == "" || results[1].Location == nil || results[1].Location.DisplayName != "Berlin" {
+		t.Errorf("unexpected results from offset 1: %+v", results)
+	}
+}
+
+func TestManagerResumesJobs(t *testing.T) {
+	records := store.NewMemoryStore().(store.RecordStore)
+	ctx := context.Background()
+
+	// A job that was interrupted after its first query, e.g. by a restart
+	first := NewManager(records, resolveTest, time.Second, 0)
+	submitter := &Submitter{IP: "192.0.2.1", KeyID: "abc", KeyName: "client", DailyMisses: 100}
+	job, err := first.Submit(ctx, []string{"Paris", "Berlin"}, submitter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job, err = first.loadJob(ctx, job.ID); err != nil {
+		t.Fatal(err)
+	}
+	job.Status = StatusRunning
+	job.Completed = 1
+	if err := first.saveResult(ctx, job.ID, Result{Index: 0, Query: "Paris", Location: &location.Location{DisplayName: "Paris"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.saveJob(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var resolved []string
+	var submitters []Submitter
+	second := NewManager(records, func(ctx context.Context, submitter *Submitter, query string) (location.Location, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		resolved = append(resolved, query)
+		if submitter != nil {
+			submitters = append(submitters, *submitter)
+		}
+		return resolveTest(ctx, submitter, query)
+	}, time.Second, 0)
+	stop := runManager(second)
+	defer stop()
+	waitForJob(t, second, job.ID)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(resolved) != 1 || resolved[0] != "Berlin" {
+		t.Errorf("expected only the remaining query to be resolved, got %v", resolved)
+	}
+	if len(submitters) != 1 || submitters[0] != *submitter {
+		t.Errorf("expected the query to be resolved for its submitter, got %+v", submitters)
+	}
+	if results := collectResults(t, second, job.ID, 0); len(results) != 2 {
+		t.Errorf("expected 2 results, got %d", len(results))
+	}
+}
+
+func TestManagerRetriesQueries(t *testing.T) {
+	retryBaseDelay = time.Millisecond
+	defer func() { retryBaseDelay = time.Second }()
+
+	// Paris succeeds on its third attempt, whereas Berlin never succeeds
+	attempts := make(map[string]int)
+	resolve := func(_ context.Context, _ *Submitter, query string) (location.Location, error) {
+		attempts[query]++
+		if query == "Paris" && attempts[query] == 1 {
+			return location.Location{}, &RetryError{Err: errors.New("rate-limited"), After: time.Millisecond}
+		} else if query == "Berlin" || attempts[query] == 2 {
+			return location.Location{}, &RetryError{Err: errors.New("unavailable")}
+		}
+		return location.Location{DisplayName: query}, nil
+	}
+	records := store.NewMemoryStore().(store.RecordStore)
+	manager := NewManager(records, resolve, time.Second, 0)
+	ctx := context.Background()
+
+	job, err := manager.Submit(ctx, []string{"Paris", "Berlin"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager.process(ctx, job.ID)
+
+	job, _ = manager.Get(ctx, job.ID)
+	if job.Status != StatusCompleted || job.Failed != 1 {
+		t.Errorf("expected only Berlin to fail, got %+v", job)
+	}
+	if attempts["Paris"] != 3 || attempts["Berlin"] != maxAttempts {
+		t.Errorf("expected 3 attempts for Paris and %d for Berlin, got %v", maxAttempts, attempts)
+	}
+	results := collectResults(t, manager, job.ID, 0)
+	if results[0].Location == nil || results[1].Error != "unavailable" {
+		t.Errorf("unexpected results %+v", results)
+	}
+}
+
+func TestManagerDeletesExpiredJobs(t *testing.T) {
+	records := store.NewMemoryStore().(store.RecordStore)
+	manager := NewManager(records, resolveTest, time.Second, time.Hour)
+	ctx := context.Background()
+
+	job, err := manager.Submit(ctx, []string{"Paris"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager.process(ctx, job.ID)
+
+	// Not yet expired
+	manager.deleteExpired(ctx)
+	if _, err := manager.Get(ctx, job.ID); err != nil {
+		t.Fatalf("expected the job to be retained, got %v", err)
+	}
+
+	job, _ = manager.Get(ctx, job.ID)
+	finished := time.Now().Add(-2 * time.Hour)
+	job.FinishedAt = &finished
+	if err := manager.saveJob(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	manager.deleteExpired(ctx)
+	if _, err := manager.Get(ctx, job.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the job to be deleted, got %v", err)
+	}
+	if value, _ := records.GetRecord(ctx, resultsKindPrefix+job.ID, resultID(0)); value != nil {
+		t.Error("expected the results to be deleted")
+	}
+}
+
+func TestManagerLease(t *testing.T) {
+	leaseDuration = 150 * time.Millisecond
+	defer func() { leaseDuration = 30 * time.Second }()
+
+	// Counts how often each query is resolved, by either manager
+	var mu sync.Mutex
+	resolved := make(map[string]int)
+	resolve := func(ctx context.Context, submitter *Submitter, query string) (location.Location, error) {
+		mu.Lock()
+		resolved[query]++
+		mu.Unlock()
+		return resolveTest(ctx, submitter, query)
+	}
+	records := store.NewMemoryStore().(store.RecordStore)
+	first := NewManager(records, resolve, time.Second, 0)
+	second := NewManager(records, resolve, time.Second, 0)
+	ctx := context.Background()
+
+	// A job submitted to the second manager is processed by the first, which holds the lease
+	stopFirst := runManager(first)
+	time.Sleep(50 * time.Millisecond)
+	stopSecond := runManager(second)
+	defer stopSecond()
+	job, err := second.Submit(ctx, []string{"Paris", "Berlin"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForJob(t, second, job.ID)
+
+	// Once the first manager stops, the second acquires the lease
+	stopFirst()
+	job, err = second.Submit(ctx, []string{"Madrid"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForJob(t, second, job.ID)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := map[string]int{"Paris": 1, "Berlin": 1, "Madrid": 1}; !reflect.DeepEqual(resolved, want) {
+		t.Errorf("expected each query to be resolved once, got %v", resolved)
+	}
+}
+
+func TestManagerProgress(t *testing.T) {
+	pollInterval = 10 * time.Millisecond
+	defer func() { pollInterval = time.Second }()
+
+	records := store.NewMemoryStore().(store.RecordStore)
+	manager := NewManager(records, resolveTest, time.Second, 0)
+
+	// Without the lease, the progress of a job is polled
+	select {
+	case <-manager.Progress("a"):
+	case <-time.After(time.Second):
+		t.Fatal("expected the progress to be polled without the lease")
+	}
+
+	// With the lease, only the progress of the job itself is notified
+	manager.setLeased(true)
+	progressA, progressB := manager.Progress("a"), manager.Progress("b")
+	manager.notifyProgress("b")
+	select {
+	case <-progressA:
+		t.Error("expected no progress of job a")
+	case <-progressB:
+	case <-time.After(time.Second):
+		t.Error("expected progress of job b")
+	}
+
+	// Losing the lease wakes those waiting, to poll instead
+	manager.setLeased(false)
+	select {
+	case <-progressA:
+	case <-time.After(time.Second):
+		t.Error("expected progress of job a once the lease was lost")
+	}
+}
+
+func TestManagerAcquireLease(t *testing.T) {
+	records := store.NewMemoryStore().(store.RecordStore)
+	manager := NewManager(records, resolveTest, time.Second, 0)
+	ctx := context.Background()
+
+	if expiresAt, err := manager.acquireLease(ctx, "first"); err != nil || expiresAt.IsZero() {
+		t.Fatalf("expected the lease to be acquired, got %v", err)
+	}
+	if expiresAt, err := manager.acquireLease(ctx, "second"); err != nil || !expiresAt.IsZero() {
+		t.Fatalf("expected the lease to be held by another owner, got %v (%v)", expiresAt, err)
+	}
+	if expiresAt, err := manager.acquireLease(ctx, "first"); err != nil || expiresAt.IsZero() {
+		t.Fatalf("expected the lease to be renewed, got %v", err)
+	}
+
+	// Once released, another owner acquires it without waiting for it to expire
+	manager.releaseLease(ctx, "first")
+	if expiresAt, err := manager.acquireLease(ctx, "second"); err != nil || expiresAt.IsZero() {
+		t.Fatalf("expected the released lease to be acquired, got %v", err)
+	}
+}
+
+// resolveTest resolves every query to a location of the same name, except Atlantis which is not found.
+func resolveTest(_ context.Context, _ *Submitter, query string) (location.Location, error) {
+	if query == "Atlantis" {
+		return location.Location{}, errors.New("no locations found for query: Atlantis")
+	}
+	return location.Location{DisplayName: query}, nil
+}
+
+// runManager runs the manager in the background, returning a function to stop it.
+func runManager(manager *Manager) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		manager.Run(ctx)
+		close(done)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// waitForJob waits until a job has finished.
+func waitForJob(t *testing.T, manager *Manager, id string) *Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := manager.Get(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		} else if job.Finished() {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for the job to finish")
+	return nil
+}
+
+// collectResults retrieves the results of a job, from an offset.
+func collectResults(t *testing.T, manager *Manager, id string, offset int) []Result {
+	t.Helper()
+	var results []Result
+	err := manager.Results(context.Background(), id, offset, func(result Result) error {
+		results = append(results, result)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return results
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owenfeehan/geocoding-nominatim-cache/fetcher"
+	"github.com/owenfeehan/geocoding-nominatim-cache/jobs"
+	"github.com/owenfeehan/geocoding-nominatim-cache/location"
+	"github.com/owenfeehan/geocoding-nominatim-cache/router"
+	"github.com/owenfeehan/geocoding-nominatim-cache/store"
+)
+
+func TestJobEvents(t *testing.T) {
+	defer func(interval time.Duration) { heartbeatInterval = interval }(heartbeatInterval)
+	heartbeatInterval = 10 * time.Millisecond
+
+	// Queries are only resolved once the stream has begun
+	gate := make(chan struct{})
+	server, manager := createJobServer(t, func(ctx context.Context, _ *jobs.Submitter, query string) (location.Location, error) {
+		<-gate
+		return location.Location{DisplayName: query}, nil
+	})
+	job, err := manager.Submit(context.Background(), []string{"Paris", "Brussels", "Madrid"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := requestJobEvents(t, server.URL+"/jobs/"+job.ID+"/events", "")
+	defer response.Body.Close()
+	events := bufio.NewReader(response.Body)
+
+	if event := readEvent(t, events); event.name != "heartbeat" {
+		t.Fatalf("expected a heartbeat before any result, got %+v", event)
+	}
+	close(gate)
+
+	var ids []string
+	for {
+		event := readEvent(t, events)
+		if event.name == "result" {
+			var result jobs.Result
+			if err := json.Unmarshal([]byte(event.data), &result); err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, event.id)
+		} else if event.name == "done" {
+			if !strings.Contains(event.data, `"status":"completed"`) {
+				t.Errorf("expected the job to be completed, got %s", event.data)
+			}
+			break
+		}
+	}
+	if got := strings.Join(ids, ","); got != "0,1,2" {
+		t.Errorf("expected results 0,1,2, got %s", got)
+	}
+}
+
+func TestJobEventsEndOnShutdown(t *testing.T) {
+	// The query is never resolved, so the stream would otherwise continue until the client disconnects
+	gate := make(chan struct{})
+	defer close(gate)
+	_, manager := createJobServer(t, func(ctx context.Context, _ *jobs.Submitter, query string) (location.Location, error) {
+		<-gate
+		return location.Location{DisplayName: query}, nil
+	})
+	job, err := manager.Submit(context.Background(), []string{"Paris"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine := gin.New()
+	engine.GET("/jobs/:id/events", (&app{Jobs: manager}).JobEvents)
+	shutdown := make(chan struct{})
+	request := httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID+"/events", nil)
+	request = request.WithContext(router.WithShutdown(request.Context(), shutdown))
+
+	served := make(chan struct{})
+	go func() {
+		engine.ServeHTTP(httptest.NewRecorder(), request)
+		close(served)
+	}()
+	close(shutdown)
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the stream to end when the server shuts down")
+	}
+}
+
+func TestJobsOnlyFoundBySubmitter(t *testing.T) {
+	records := store.NewMemoryStore().(store.RecordStore)
+	ctx := context.Background()
+	for _, key := range []router.APIKey{{Key: "first-secret", Name: "first"}, {Key: "second-secret", Name: "second"}} {
+		if err := router.SaveStoreKey(ctx, records, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The manager is not run, as only the records of the job are read
+	a := &app{Jobs: jobs.NewManager(records, nil, time.Second, 0), JobMaxSize: 10}
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(router.Authenticate(router.NewStoreKeySource(records)))
+	engine.POST("/jobs", a.SubmitJob)
+	engine.GET("/jobs/:id", a.GetJob)
+	engine.GET("/jobs/:id/results", a.JobResults)
+	engine.GET("/jobs/:id/events", a.JobEvents)
+
+	serve := func(method string, url string, key string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, url, strings.NewReader(body))
+		request.Header.Set(router.APIKeyHeader, key)
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	recorder := serve(http.MethodPost, "/jobs", "first-secret", `["Paris"]`)
+	var job jobs.Job
+	if err := json.Unmarshal(recorder.Body.Bytes(), &job); recorder.Code != http.StatusAccepted || err != nil {
+		t.Fatalf("expected the job to be submitted, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	if got := serve(http.MethodGet, "/jobs/"+job.ID, "first-secret", "").Code; got != http.StatusOK {
+		t.Errorf("expected the submitter to find the job, got %d", got)
+	}
+	for _, url := range []string{"/jobs/" + job.ID, "/jobs/" + job.ID + "/results", "/jobs/" + job.ID + "/events"} {
+		if got := serve(http.MethodGet, url, "second-secret", "").Code; got != http.StatusNotFound {
+			t.Errorf("expected another key not to find %s, got %d", url, got)
+		}
+	}
+}
+
+func TestJobEventsResume(t *testing.T) {
+	server, manager := createJobServer(t, func(_ context.Context, _ *jobs.Submitter, query string) (location.Location, error) {
+		return location.Location{DisplayName: query}, nil
+	})
+	job, err := manager.Submit(context.Background(), []string{"Paris", "Brussels", "Madrid"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for job.Status != jobs.StatusCompleted {
+		time.Sleep(10 * time.Millisecond)
+		if job, err = manager.Get(context.Background(), job.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	response := requestJobEvents(t, server.URL+"/jobs/"+job.ID+"/events", "1")
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(body); !strings.HasPrefix(got, "id:2\nevent:result\n") || strings.Contains(got, "Brussels") || !strings.Contains(got, "event:done\n") {
+		t.Errorf("expected only the last result and done, got %s", got)
+	}
+
+	for url, want := range map[string]int{
+		server.URL + "/jobs/unknown/events":                 http.StatusNotFound,
+		server.URL + "/jobs/" + job.ID + "/events?offset=x": http.StatusBadRequest,
+	} {
+		response := requestJobEvents(t, url, "")
+		response.Body.Close()
+		if response.StatusCode != want {
+			t.Errorf("expected status %d for %s, got %d", want, url, response.StatusCode)
+		}
+	}
+}
+
+func TestResolveJobQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		locs      []location.Location
+		wantRetry bool
+	}{
+		{"found", nil, []location.Location{{DisplayName: "Brussels"}}, false},
+		{"not found", nil, []location.Location{}, false},
+		{"rejected", &fetcher.StatusError{StatusCode: http.StatusBadRequest}, nil, false},
+		{"rate-limited", &fetcher.StatusError{StatusCode: http.StatusTooManyRequests}, nil, true},
+		{"unavailable", &fetcher.StatusError{StatusCode: http.StatusBadGateway}, nil, true},
+		{"tripped", &fetcher.CircuitOpenError{RetryAfter: time.Minute}, nil, true},
+		{"unreachable", errors.New("connection refused"), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &app{
+				Store:   store.NewMemoryStore(),
+				Fetcher: &mockFetcher{fetchFunc: func(string) ([]location.Location, error) { return tt.locs, tt.err }},
+			}
+			_, err := a.resolveJobQuery(context.Background(), nil, "Brussels")
+			var retryErr *jobs.RetryError
+			if errors.As(err, &retryErr) != tt.wantRetry {
+				t.Errorf("expected retry %v, got %v", tt.wantRetry, err)
+			}
+		})
+	}
+}
+
+func TestResolveJobQueryChargesSubmitter(t *testing.T) {
+	a := &app{
+		Store:    store.NewMemoryStore(),
+		Fetcher:  &mockFetcher{fetchFunc: func(string) ([]location.Location, error) { return []location.Location{{DisplayName: "Paris"}}, nil }},
+		Limiters: router.NewLimiters(router.RateLimits{}),
+	}
+	submitter := &jobs.Submitter{IP: "192.0.2.1", KeyID: "abc", KeyName: "client", DailyMisses: 1}
+
+	if _, err := a.resolveJobQuery(context.Background(), submitter, "Paris"); err != nil {
+		t.Fatalf("expected the first miss to be allowed, got %v", err)
+	}
+	if _, err := a.resolveJobQuery(context.Background(), submitter, "Paris"); err != nil {
+		t.Fatalf("expected a cached query to be allowed, got %v", err)
+	}
+
+	_, err := a.resolveJobQuery(context.Background(), submitter, "Berlin")
+	var retryErr *jobs.RetryError
+	if !errors.As(err, &retryErr) || retryErr.After <= 0 {
+		t.Fatalf("expected a retry after the daily limit, got %v", err)
+	}
+}
+
+// sseEvent is an event read from a Server-Sent Events stream.
+type sseEvent struct {
+	id, name, data string
+}
+
+// createJobServer creates a server for the events of jobs, whose queries are resolved by resolve.
+func createJobServer(t *testing.T, resolve jobs.Resolver) (*httptest.Server, *jobs.Manager) {
+	t.Helper()
+	manager := jobs.NewManager(store.NewMemoryStore().(store.RecordStore), resolve, time.Second, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		manager.Run(ctx)
+		close(done)
+	}()
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/jobs/:id/events", (&app{Jobs: manager}).JobEvents)
+	server := httptest.NewServer(engine)
+	t.Cleanup(func() {
+		server.Close()
+		cancel()
+		<-done
+	})
+	return server, manager
+}
+
+// requestJobEvents requests a stream of events, resuming after lastEventID if not empty.
+func requestJobEvents(t *testing.T, url string, lastEventID string) *http.Response {
+	t.Helper()
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		request.Header.Set("Last-Event-ID", lastEventID)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+// readEvent reads the next event from a stream, failing if the stream ends first.
+func readEvent(t *testing.T, reader *bufio.Reader) sseEvent {
+	t.Helper()
+	var event sseEvent
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream ended before an event: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return event
+		}
+		field, value, _ := strings.Cut(line, ":")
+		switch field {
+		case "id":
+			event.id = value
+		case "event":
+			event.name = value
+		case "data":
+			event.data = value
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/owenfeehan/geocoding-nominatim-cache/router"
+	"github.com/owenfeehan/geocoding-nominatim-cache/store"
+)
+
+// runKeys implements the keys subcommand, which adds, lists or revokes API keys held in a store.
+//
+// Example:
+//
+//	geocoding-nominatim-cache keys add --redis localhost:6379 --name mobile-app --daily-misses 1000
+//	geocoding-nominatim-cache keys list --redis localhost:6379
+//	geocoding-nominatim-cache keys revoke --redis localhost:6379 --name mobile-app
+//
+// The store is specified with the same flags as the server (e.g. --redis-key-prefix), so the keys are found by it.
+func runKeys(args []string) error {
+	if len(args) == 0 {
+		return errors.New("an action is required: add, list or revoke")
+	}
+	action := args[0]
+
+	flags := flag.NewFlagSet("keys "+action, flag.ExitOnError)
+	redis := flags.String("redis", "", "The address (or URL) of the Redis server holding the keys, as for the server. If not set, the BadgerDB store is used.")
+	redisConn := addRedisFlags(flags, "")
+	badgerPath := flags.String("badger-path", "", "The directory of the BadgerDB store holding the keys, as for the server. If not set, a directory under the user's app data directory is used.")
+	name := flags.String("name", "", "The name of the API key (to add or revoke).")
+	missesPerMinute := flags.Int("misses-per-minute", 0, "The maximum number of uncached requests per minute for a new key. If zero, the rate is unlimited.")
+	dailyMisses := flags.Int("daily-misses", 0, "The maximum number of uncached requests per day for a new key. If zero, there is no daily quota.")
+	class := flags.String("class", "", "The priority of uncached requests for a new key, interactive or bulk. If empty, they are interactive.")
+	admin := flags.Bool("admin", false, "Whether a new key may also call the admin endpoints e.g. to write a backup.")
+	debug := flags.Bool("debug", false, "Enable debug logging.")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	configureLogging(*debug)
+
+	locStore, err := createStore(redisConn.options(*redis), false, store.BadgerOptions{Path: *badgerPath})
+	if errors.Is(err, store.ErrLocked) {
+		return fmt.Errorf("failed to open the store: %w; API keys in a BadgerDB store can only be managed while the service is stopped", err)
+	} else if err != nil {
+		return fmt.Errorf("failed to open the store: %w", err)
+	}
+	defer closeStore(locStore)
+
+	records, ok := locStore.(store.RecordStore)
+	if !ok {
+		return store.ErrRecordsUnsupported
+	}
+
+	ctx := context.Background()
+	switch action {
+	case "add":
+		return addKey(ctx, records, router.APIKey{Name: *name, MissesPerMinute: *missesPerMinute, DailyMisses: *dailyMisses, Class: *class, Admin: *admin})
+	case "list":
+		return listKeys(ctx, records)
+	case "revoke":
+		return revokeKey(ctx, records, *name)
+	default:
+		return fmt.Errorf("unknown action %q, expected add, list or revoke", action)
+	}
+}
+
+// addKey generates a secret for a new API key and saves it, printing the secret as it cannot be recovered later.
+func addKey(ctx context.Context, records store.RecordStore, key router.APIKey) error {
+	if key.Name == "" {
+		return errors.New("--name must be specified")
+	}
+	if _, err := key.Priority(); err != nil {
+		return fmt.Errorf("invalid --class: %w", err)
+	}
+
+	existing, err := findKeyIDs(ctx, records, key.Name)
+	if err != nil {
+		return err
+	} else if len(existing) > 0 {
+		return fmt.Errorf("an API key named %s already exists", key.Name)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	key.Key = base64.RawURLEncoding.EncodeToString(secret)
+
+	if err := router.SaveStoreKey(ctx, records, key); err != nil {
+		return err
+	}
+	fmt.Printf("Added API key %s: %s\n", key.Name, key.Key)
+	return nil
+}
+
+// listKeys prints the name and limits of each API key (but not the secrets, which are not stored).
+func listKeys(ctx context.Context, records store.RecordStore) error {
+	return records.ForEachRecord(ctx, router.KeyRecordKind, func(_ string, value []byte) error {
+		var key router.APIKey
+		if err := json.Unmarshal(value, &key); err != nil {
+			return fmt.Errorf("cannot parse API key record: %w", err)
+		}
+		priority, _ := key.Priority()
+		fmt.Printf("%s\tmisses per minute: %s\tdaily misses: %s\tclass: %s\tadmin: %t\n", key.Name, describeLimit(key.MissesPerMinute), describeLimit(key.DailyMisses), priority, key.Admin)
+		return nil
+	})
+}
+
+// revokeKey deletes the API key with the given name.
+func revokeKey(ctx context.Context, records store.RecordStore, name string) error {
+	if name == "" {
+		return errors.New("--name must be specified")
+	}
+
+	ids, err := findKeyIDs(ctx, records, name)
+	if err != nil {
+		return err
+	} else if len(ids) == 0 {
+		return fmt.Errorf("no API key named %s exists", name)
+	}
+
+	for _, id := range ids {
+		if err := records.DeleteRecord(ctx, router.KeyRecordKind, id); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Revoked API key %s\n", name)
+	return nil
+}
+
+// findKeyIDs finds the identifiers of the records of API keys with the given name.
+func findKeyIDs(ctx context.Context, records store.RecordStore, name string) ([]string, error) {
+	var ids []string
+	err := records.ForEachRecord(ctx, router.KeyRecordKind, func(id string, value []byte) error {
+		var key router.APIKey
+		if err := json.Unmarshal(value, &key); err != nil {
+			return fmt.Errorf("cannot parse API key record: %w", err)
+		}
+		if key.Name == name {
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	return ids, err
+}
+
+// describeLimit describes a limit, where zero is unlimited.
+func describeLimit(limit int) string {
+	if limit == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprint(limit)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/owenfeehan/geocoding-nominatim-cache/store"
+)
+
+func TestKeysUseRedisKeyPrefix(t *testing.T) {
+	server := miniredis.RunT(t)
+	if err := runKeys([]string{"add", "--redis", server.Addr(), "--redis-key-prefix", "foo:", "--name", "mobile-app"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The key is found in the store, as opened by the server with the same prefix
+	locStore, err := store.NewRedisStore(store.RedisOptions{Address: server.Addr(), KeyPrefix: "foo:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeStore(locStore)
+	ids, err := findKeyIDs(context.Background(), locStore.(store.RecordStore), "mobile-app")
+	if err != nil || len(ids) != 1 {
+		t.Errorf("expected the key to be found with the prefix, got %v (%v)", ids, err)
+	}
+}
+
+func TestKeysBadgerLocked(t *testing.T) {
+	path := t.TempDir()
+	locStore, err := store.NewBadgerStore(&path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeStore(locStore)
+
+	err = runKeys([]string{"list", "--badger-path", path})
+	if err == nil || !strings.Contains(err.Error(), "only be managed while the service is stopped") {
+		t.Errorf("expected an error explaining the store is locked, got %v", err)
+	}
+}
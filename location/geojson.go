@@ -0,0 +1,161 @@
+package location
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// Licence is the attribution required for locations from Nominatim, as data from OpenStreetMap.
+const Licence = "Data © OpenStreetMap contributors, ODbL 1.0. http://osm.org/copyright"
+
+// FeatureCollection is a GeoJSON feature collection of locations.
+type FeatureCollection struct {
+	Type    string `json:"type" example:"FeatureCollection"`
+	Licence string `json:"licence,omitempty"`
+
+	// Describes the request, in GeocodeJSON only.
+	Geocoding *GeocodingHeader `json:"geocoding,omitempty"`
+
+	Features []Feature `json:"features"`
+}
+
+// Feature is a GeoJSON feature for a location.
+type Feature struct {
+	Type       string         `json:"type" example:"Feature"`
+	Properties map[string]any `json:"properties"`
+
+	// The bounding box of the location as the minimum longitude, minimum latitude, maximum longitude and maximum
+	// latitude, if known.
+	BBox []float64 `json:"bbox,omitempty"`
+
+	// The outline of the location if known, or else a point at its coordinates, or nil if they are invalid.
+	Geometry *Geometry `json:"geometry"`
+}
+
+// Geometry is a GeoJSON geometry.
+type Geometry struct {
+	Type        string `json:"type" example:"Point"`
+	Coordinates any    `json:"coordinates" swaggertype:"array,number"`
+}
+
+// GeocodingHeader describes a GeocodeJSON response.
+type GeocodingHeader struct {
+	Version     string `json:"version" example:"0.1.0"`
+	Attribution string `json:"attribution"`
+	Licence     string `json:"licence" example:"ODbL"`
+	Query       string `json:"query,omitempty" example:"Brussels"`
+}
+
+// geocodeJSONAddress maps Nominatim's components of an address to those of GeocodeJSON, in order of preference.
+var geocodeJSONAddress = []struct {
+	geocodeJSON string
+	nominatim   []string
+}{
+	{"housenumber", []string{"house_number"}},
+	{"street", []string{"road", "pedestrian", "footway"}},
+	{"locality", []string{"hamlet", "neighbourhood"}},
+	{"postcode", []string{"postcode"}},
+	{"district", []string{"suburb", "city_district", "borough"}},
+	{"city", []string{"city", "town", "village", "municipality"}},
+	{"county", []string{"county"}},
+	{"state", []string{"state", "region"}},
+	{"country", []string{"country"}},
+	{"country_code", []string{"country_code"}},
+}
+
+// ToGeoJSON renders locations as a GeoJSON feature collection, with the outline of each location (or else a point), as
+// in Nominatim's geojson format.
+func ToGeoJSON(locs []Location) FeatureCollection {
+	features := make([]Feature, len(locs))
+	for i, loc := range locs {
+		properties := map[string]any{"display_name": loc.DisplayName}
+		setIfPresent(properties, "place_id", loc.PlaceID)
+		setIfPresent(properties, "osm_type", loc.OSMType)
+		setIfPresent(properties, "osm_id", loc.OSMID)
+		setIfPresent(properties, "place_rank", loc.PlaceRank)
+		setIfPresent(properties, "category", loc.Class)
+		setIfPresent(properties, "type", loc.Type)
+		setIfPresent(properties, "importance", loc.Importance)
+		setIfPresent(properties, "addresstype", loc.AddressType)
+		setIfPresent(properties, "name", loc.Name)
+		if len(loc.Address) > 0 {
+			properties["address"] = loc.Address
+		}
+		features[i] = Feature{Type: "Feature", Properties: properties, BBox: loc.bbox(), Geometry: loc.geometry()}
+	}
+	return FeatureCollection{Type: "FeatureCollection", Licence: Licence, Features: features}
+}
+
+// ToGeocodeJSON renders locations as a GeocodeJSON feature collection, as in Nominatim's geocodejson format.
+//
+// query describes the request, or is empty to omit it.
+func ToGeocodeJSON(locs []Location, query string) FeatureCollection {
+	features := make([]Feature, len(locs))
+	for i, loc := range locs {
+		geocoding := map[string]any{"label": loc.DisplayName}
+		setIfPresent(geocoding, "place_id", loc.PlaceID)
+		setIfPresent(geocoding, "osm_type", loc.OSMType)
+		setIfPresent(geocoding, "osm_id", loc.OSMID)
+		setIfPresent(geocoding, "osm_key", loc.Class)
+		setIfPresent(geocoding, "osm_value", loc.Type)
+		setIfPresent(geocoding, "type", loc.AddressType)
+		setIfPresent(geocoding, "name", loc.Name)
+		for _, component := range geocodeJSONAddress {
+			for _, name := range component.nominatim {
+				if value := loc.Address[name]; value != "" {
+					geocoding[component.geocodeJSON] = value
+					break
+				}
+			}
+		}
+		features[i] = Feature{Type: "Feature", Properties: map[string]any{"geocoding": geocoding}, Geometry: loc.geometry()}
+	}
+	header := &GeocodingHeader{Version: "0.1.0", Attribution: Licence, Licence: "ODbL", Query: query}
+	return FeatureCollection{Type: "FeatureCollection", Features: features, Geocoding: header}
+}
+
+// geometry decodes the outline of the location, or else creates a point at its coordinates.
+func (l Location) geometry() *Geometry {
+	if len(l.GeoJSON) > 0 {
+		var outline Geometry
+		if err := json.Unmarshal(l.GeoJSON, &outline); err == nil && outline.Type != "" {
+			return &outline
+		}
+	}
+	return l.point()
+}
+
+// point creates a point geometry at the coordinates of the location, or returns nil if they are invalid.
+func (l Location) point() *Geometry {
+	lat, errLat := strconv.ParseFloat(l.Latitude, 64)
+	lon, errLon := strconv.ParseFloat(l.Longitude, 64)
+	if errLat != nil || errLon != nil {
+		return nil
+	}
+	return &Geometry{Type: "Point", Coordinates: []float64{lon, lat}}
+}
+
+// bbox converts the bounding box of the location to GeoJSON's order, or returns nil if it is missing or invalid.
+func (l Location) bbox() []float64 {
+	if len(l.BoundingBox) != 4 {
+		return nil
+	}
+	var values [4]float64
+	for i, value := range l.BoundingBox {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil
+		}
+		values[i] = parsed
+	}
+	// Nominatim orders the box as latitudes then longitudes, whereas GeoJSON orders it as a minimum then maximum point
+	return []float64{values[2], values[0], values[3], values[1]}
+}
+
+// setIfPresent sets a property, unless value is its zero value (i.e. unknown).
+func setIfPresent[T comparable](properties map[string]any, name string, value T) {
+	var zero T
+	if value != zero {
+		properties[name] = value
+	}
+}
@@ -0,0 +1,87 @@
+package location
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+var testLocation = Location{
+	DisplayName: "Brussels, Belgium",
+	Latitude:    "50.8465573",
+	Longitude:   "4.351697",
+	PlaceID:     98182699,
+	OSMType:     "relation",
+	OSMID:       58004,
+	Class:       "boundary",
+	Type:        "administrative",
+	AddressType: "city",
+	Address:     map[string]string{"town": "Brussels", "country": "Belgium", "country_code": "be"},
+	BoundingBox: []string{"50.7963", "50.9136", "4.3139", "4.4369"},
+}
+
+func TestToGeoJSON(t *testing.T) {
+	collection := ToGeoJSON([]Location{testLocation, {DisplayName: "Unknown", Latitude: "invalid"}})
+	if collection.Type != "FeatureCollection" || len(collection.Features) != 2 {
+		t.Fatalf("expected a collection of two features, got %+v", collection)
+	}
+
+	feature := collection.Features[0]
+	if want := []float64{4.351697, 50.8465573}; !reflect.DeepEqual(feature.Geometry.Coordinates, want) {
+		t.Errorf("expected coordinates %v, got %v", want, feature.Geometry.Coordinates)
+	}
+	if want := []float64{4.3139, 50.7963, 4.4369, 50.9136}; !reflect.DeepEqual(feature.BBox, want) {
+		t.Errorf("expected bbox %v, got %v", want, feature.BBox)
+	}
+	if feature.Properties["category"] != "boundary" || feature.Properties["osm_id"] != int64(58004) {
+		t.Errorf("unexpected properties %v", feature.Properties)
+	}
+
+	// Invalid coordinates have no geometry, and missing fields are omitted
+	if invalid := collection.Features[1]; invalid.Geometry != nil || invalid.BBox != nil || len(invalid.Properties) != 1 {
+		t.Errorf("expected only a display name, got %+v", invalid)
+	}
+}
+
+func TestToGeoJSONOutline(t *testing.T) {
+	outlined := testLocation
+	outlined.GeoJSON = json.RawMessage(`{"type":"Polygon","coordinates":[[[4.31,50.79],[4.43,50.79],[4.43,50.91],[4.31,50.79]]]}`)
+
+	// The outline replaces the point, in each format with features
+	for _, collection := range []FeatureCollection{ToGeoJSON([]Location{outlined}), ToGeocodeJSON([]Location{outlined}, "")} {
+		if geometry := collection.Features[0].Geometry; geometry == nil || geometry.Type != "Polygon" {
+			t.Errorf("expected a polygon, got %+v", geometry)
+		}
+	}
+}
+
+func TestToGeocodeJSON(t *testing.T) {
+	collection := ToGeocodeJSON([]Location{testLocation}, "Brussels")
+	if collection.Geocoding == nil || collection.Geocoding.Query != "Brussels" {
+		t.Fatalf("expected a geocoding header with the query, got %+v", collection.Geocoding)
+	}
+
+	geocoding := collection.Features[0].Properties["geocoding"].(map[string]any)
+	want := map[string]any{
+		"label":        "Brussels, Belgium",
+		"place_id":     int64(98182699),
+		"osm_type":     "relation",
+		"osm_id":       int64(58004),
+		"osm_key":      "boundary",
+		"osm_value":    "administrative",
+		"type":         "city",
+		"city":         "Brussels",
+		"country":      "Belgium",
+		"country_code": "be",
+	}
+	if !reflect.DeepEqual(geocoding, want) {
+		t.Errorf("expected %v, got %v", want, geocoding)
+	}
+}
+
+func TestToJSONv2(t *testing.T) {
+	rendered := ToJSONv2([]Location{testLocation})
+	if len(rendered) != 1 || rendered[0].Category != "boundary" || rendered[0].Licence != Licence {
+		t.Errorf("unexpected jsonv2 %+v", rendered)
+	}
+}
@@ -0,0 +1,49 @@
+package location
+
+import "encoding/json"
+
+// JSONv2 is a location in Nominatim's jsonv2 format, which names the class of a location as its category.
+type JSONv2 struct {
+	PlaceID     int64             `json:"place_id,omitempty" example:"98182699"`
+	Licence     string            `json:"licence"`
+	OSMType     string            `json:"osm_type,omitempty" example:"relation"`
+	OSMID       int64             `json:"osm_id,omitempty" example:"58004"`
+	Latitude    string            `json:"lat" example:"50.8465573"`
+	Longitude   string            `json:"lon" example:"4.351697"`
+	Category    string            `json:"category,omitempty" example:"boundary"`
+	Type        string            `json:"type,omitempty" example:"administrative"`
+	PlaceRank   int               `json:"place_rank,omitempty" example:"16"`
+	Importance  float64           `json:"importance,omitempty" example:"0.69"`
+	AddressType string            `json:"addresstype,omitempty" example:"city"`
+	Name        string            `json:"name,omitempty" example:"Bruxelles - Brussel"`
+	DisplayName string            `json:"display_name" example:"Brussels, Brussels-Capital, Belgium"`
+	Address     map[string]string `json:"address,omitempty"`
+	BoundingBox []string          `json:"boundingbox,omitempty"`
+	GeoJSON     json.RawMessage   `json:"geojson,omitempty" swaggertype:"object"`
+}
+
+// ToJSONv2 renders locations in Nominatim's jsonv2 format.
+func ToJSONv2(locs []Location) []JSONv2 {
+	rendered := make([]JSONv2, len(locs))
+	for i, loc := range locs {
+		rendered[i] = JSONv2{
+			PlaceID:     loc.PlaceID,
+			Licence:     Licence,
+			OSMType:     loc.OSMType,
+			OSMID:       loc.OSMID,
+			Latitude:    loc.Latitude,
+			Longitude:   loc.Longitude,
+			Category:    loc.Class,
+			Type:        loc.Type,
+			PlaceRank:   loc.PlaceRank,
+			Importance:  loc.Importance,
+			AddressType: loc.AddressType,
+			Name:        loc.Name,
+			DisplayName: loc.DisplayName,
+			Address:     loc.Address,
+			BoundingBox: loc.BoundingBox,
+			GeoJSON:     loc.GeoJSON,
+		}
+	}
+	return rendered
+}
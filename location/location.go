@@ -1,13 +1,59 @@
 // Package location contains classes relevant to storing, displaying or processing a geocoding result.
 package location
 
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
 // Location represents a Nominatim geocoding result.
 //
 // Latitude and longitude are stored as strings to maintain precision.
 //
-// The JSON names are deliberately chosen to match the Nominatim API response format.
+// The JSON names are deliberately chosen to match the Nominatim API response format. Only the display name and
+// coordinates are guaranteed, as the other fields are missing from locations cached by earlier versions.
 type Location struct {
 	DisplayName string `json:"display_name"`
 	Latitude    string `json:"lat"`
 	Longitude   string `json:"lon"`
+
+	// Identifies the location in the Nominatim database, which differs between Nominatim instances.
+	PlaceID int64 `json:"place_id,omitempty" example:"98182699"`
+
+	// The OSM object from which the location is derived, whose type is node, way or relation.
+	OSMType string `json:"osm_type,omitempty" example:"relation"`
+	OSMID   int64  `json:"osm_id,omitempty" example:"58004"`
+
+	// The main OSM tag of the location e.g. class boundary and type administrative.
+	Class string `json:"class,omitempty" example:"boundary"`
+	Type  string `json:"type,omitempty" example:"administrative"`
+
+	// The search rank of the location, from 0 (largest e.g. a continent) to 30 (smallest e.g. a house).
+	PlaceRank int `json:"place_rank,omitempty" example:"16"`
+
+	// How important the location is, from 0 to 1, by which Nominatim ranks the results of a search.
+	Importance float64 `json:"importance,omitempty" example:"0.69"`
+
+	// The type of address of the location e.g. city, and its name in the local language.
+	AddressType string `json:"addresstype,omitempty" example:"city"`
+	Name        string `json:"name,omitempty" example:"Bruxelles - Brussel"`
+
+	// The components of the address of the location, by their Nominatim names e.g. road, city or country_code.
+	Address map[string]string `json:"address,omitempty"`
+
+	// The area covering the location, as the minimum latitude, maximum latitude, minimum longitude and maximum
+	// longitude, in that order.
+	BoundingBox []string `json:"boundingbox,omitempty" example:"50.7963,50.9136,4.3139,4.4369"`
+
+	// The outline of the location as a GeoJSON geometry e.g. a polygon, only if requested, as it can be large.
+	GeoJSON json.RawMessage `json:"geojson,omitempty" swaggertype:"object"`
+}
+
+// OSMRef identifies the OSM object of the location by its ID prefixed by its type e.g. R58004, or is empty if unknown.
+func (l Location) OSMRef() string {
+	if l.OSMType == "" || l.OSMID == 0 {
+		return ""
+	}
+	return strings.ToUpper(l.OSMType[:1]) + strconv.FormatInt(l.OSMID, 10)
 }
@@ -0,0 +1,130 @@
+package location
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// Simplified simplifies the outline of the location, so no point of the outline moves further than tolerance (in
+// degrees), as with Nominatim's polygon_threshold.
+//
+// The location is unchanged if the tolerance is not positive, or it has no outline (or one that cannot be decoded).
+func (l Location) Simplified(tolerance float64) Location {
+	if tolerance <= 0 || len(l.GeoJSON) == 0 {
+		return l
+	}
+	simplified, err := simplifyGeometry(l.GeoJSON, tolerance)
+	if err == nil {
+		l.GeoJSON = simplified
+	}
+	return l
+}
+
+// simplifyGeometry simplifies the lines and rings of a GeoJSON geometry. Points are unchanged.
+func simplifyGeometry(raw json.RawMessage, tolerance float64) (json.RawMessage, error) {
+	var geometry struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	}
+	if err := json.Unmarshal(raw, &geometry); err != nil {
+		return nil, err
+	}
+
+	var simplified any
+	switch geometry.Type {
+	case "LineString":
+		var line [][]float64
+		if err := json.Unmarshal(geometry.Coordinates, &line); err != nil {
+			return nil, err
+		}
+		simplified = simplifyLine(line, tolerance, 2)
+	case "MultiLineString", "Polygon":
+		var lines [][][]float64
+		if err := json.Unmarshal(geometry.Coordinates, &lines); err != nil {
+			return nil, err
+		}
+		simplified = simplifyLines(lines, tolerance, geometry.Type == "Polygon")
+	case "MultiPolygon":
+		var polygons [][][][]float64
+		if err := json.Unmarshal(geometry.Coordinates, &polygons); err != nil {
+			return nil, err
+		}
+		for i, rings := range polygons {
+			polygons[i] = simplifyLines(rings, tolerance, true)
+		}
+		simplified = polygons
+	default:
+		return raw, nil
+	}
+
+	return json.Marshal(struct {
+		Type        string `json:"type"`
+		Coordinates any    `json:"coordinates"`
+	}{geometry.Type, simplified})
+}
+
+// simplifyLines simplifies each line, which are the rings of a polygon if rings.
+func simplifyLines(lines [][][]float64, tolerance float64, rings bool) [][][]float64 {
+	// A ring is closed, so needs at least four points
+	minPoints := 2
+	if rings {
+		minPoints = 4
+	}
+	for i, line := range lines {
+		lines[i] = simplifyLine(line, tolerance, minPoints)
+	}
+	return lines
+}
+
+// simplifyLine simplifies a line with the Douglas-Peucker algorithm, keeping the line unchanged if it would have fewer
+// than minPoints.
+func simplifyLine(line [][]float64, tolerance float64, minPoints int) [][]float64 {
+	if len(line) <= minPoints {
+		return line
+	}
+	keep := make([]bool, len(line))
+	keep[0], keep[len(line)-1] = true, true
+
+	// Each span between kept points is divided at its furthest point, until no point is further than the tolerance
+	spans := [][2]int{{0, len(line) - 1}}
+	for len(spans) > 0 {
+		span := spans[len(spans)-1]
+		spans = spans[:len(spans)-1]
+
+		furthest, maxDistance := -1, tolerance
+		for i := span[0] + 1; i < span[1]; i++ {
+			if distance := segmentDistance(line[i], line[span[0]], line[span[1]]); distance > maxDistance {
+				furthest, maxDistance = i, distance
+			}
+		}
+		if furthest >= 0 {
+			keep[furthest] = true
+			spans = append(spans, [2]int{span[0], furthest}, [2]int{furthest, span[1]})
+		}
+	}
+
+	var simplified [][]float64
+	for i, point := range line {
+		if keep[i] {
+			simplified = append(simplified, point)
+		}
+	}
+	if len(simplified) < minPoints {
+		return line
+	}
+	return simplified
+}
+
+// segmentDistance calculates the distance from a point to the segment between start and end, treating the coordinates
+// as planar.
+func segmentDistance(point, start, end []float64) float64 {
+	if len(point) < 2 || len(start) < 2 || len(end) < 2 {
+		return 0
+	}
+	dx, dy := end[0]-start[0], end[1]-start[1]
+	t := 0.0
+	if lengthSquared := dx*dx + dy*dy; lengthSquared > 0 {
+		t = max(0, min(1, ((point[0]-start[0])*dx+(point[1]-start[1])*dy)/lengthSquared))
+	}
+	return math.Hypot(point[0]-start[0]-t*dx, point[1]-start[1]-t*dy)
+}
@@ -0,0 +1,41 @@
+package location
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSimplified(t *testing.T) {
+	// A square, with a point slightly off its bottom edge, and another far off its right edge
+	outline := `{"type":"Polygon","coordinates":[[[0,0],[0.5,0.01],[1,0],[1.5,0.5],[1,1],[0,1],[0,0]]]}`
+	loc := Location{DisplayName: "Square", GeoJSON: json.RawMessage(outline)}
+
+	tests := []struct {
+		tolerance float64
+		want      string
+	}{
+		{0, outline},
+		{0.1, `{"type":"Polygon","coordinates":[[[0,0],[1,0],[1.5,0.5],[1,1],[0,1],[0,0]]]}`},
+		{0.001, outline},
+		// The ring would collapse, so is unchanged
+		{10, outline},
+	}
+	for _, test := range tests {
+		if got := string(loc.Simplified(test.tolerance).GeoJSON); got != test.want {
+			t.Errorf("tolerance %v: expected %s, got %s", test.tolerance, test.want, got)
+		}
+	}
+	if string(loc.GeoJSON) != outline {
+		t.Errorf("expected the original location to be unchanged, got %s", loc.GeoJSON)
+	}
+
+	multi := Location{GeoJSON: json.RawMessage(`{"type":"MultiLineString","coordinates":[[[0,0],[1,0.01],[2,0]]]}`)}
+	if got := string(multi.Simplified(0.1).GeoJSON); got != `{"type":"MultiLineString","coordinates":[[[0,0],[2,0]]]}` {
+		t.Errorf("expected each line to be simplified, got %s", got)
+	}
+
+	point := Location{GeoJSON: json.RawMessage(`{"type":"Point","coordinates":[4.35,50.85]}`)}
+	if got := string(point.Simplified(0.1).GeoJSON); got != `{"type":"Point","coordinates":[4.35,50.85]}` {
+		t.Errorf("expected a point to be unchanged, got %s", got)
+	}
+}
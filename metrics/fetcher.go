@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/owenfeehan/geocoding-nominatim-cache/fetcher"
+	"github.com/owenfeehan/geocoding-nominatim-cache/location"
+)
+
+// instrumentedFetcher wraps a LocationFetcher, recording the count and latency of upstream requests by status.
+type instrumentedFetcher struct {
+	delegate fetcher.LocationFetcher
+}
+
+// InstrumentFetcher wraps a LocationFetcher to record metrics about upstream requests.
+//
+// It should directly wrap the fetcher that calls the upstream API (i.e. inside any throttling), so the latency
+// excludes any time waiting to be throttled.
+func InstrumentFetcher(delegate fetcher.LocationFetcher) fetcher.LocationFetcher {
+	return &instrumentedFetcher{delegate: delegate}
+}
+
+// Fetch records the outcome and latency of the delegate's Fetch.
+func (f *instrumentedFetcher) Fetch(ctx context.Context, req fetcher.Request) ([]location.Location, error) {
+	start := time.Now()
+	locs, err := f.delegate.Fetch(ctx, req)
+
+	status := upstreamStatus(err)
+	upstreamRequests.WithLabelValues(status).Inc()
+	upstreamDuration.WithLabelValues(status).Observe(time.Since(start).Seconds())
+	return locs, err
+}
+
+// upstreamStatus describes the outcome of an upstream request, by its HTTP status where possible.
+func upstreamStatus(err error) string {
+	var statusErr *fetcher.StatusError
+	if err == nil {
+		return "200"
+	} else if errors.As(err, &statusErr) {
+		return strconv.Itoa(statusErr.StatusCode)
+	}
+	return "error"
+}
+
+// throttleObserver records the wait time and queue depth of each lane of a throttler.
+type throttleObserver struct{}
+
+// ThrottleObserver creates an observer for fetcher.NewThrottlerWithObserver, that records the throttler's wait time and
+// queue depth, by priority lane.
+func ThrottleObserver() fetcher.ThrottleObserver {
+	// Every lane is reported, even before any request waits in it
+	for _, priority := range fetcher.Priorities {
+		throttleQueueDepth.WithLabelValues(priority.String())
+	}
+	return throttleObserver{}
+}
+
+func (throttleObserver) Queued(priority fetcher.Priority) {
+	throttleQueueDepth.WithLabelValues(priority.String()).Inc()
+}
+
+func (throttleObserver) Dequeued(priority fetcher.Priority, wait time.Duration) {
+	throttleQueueDepth.WithLabelValues(priority.String()).Dec()
+	throttleWait.WithLabelValues(priority.String()).Observe(wait.Seconds())
+}
+
+// Assert implementation
+var _ fetcher.LocationFetcher = (*instrumentedFetcher)(nil)
+var _ fetcher.ThrottleObserver = throttleObserver{}
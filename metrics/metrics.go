@@ -0,0 +1,92 @@
+// Package metrics records Prometheus metrics for the cache, the upstream API, the throttler and HTTP requests.
+//
+// Stores and fetchers are instrumented by wrapping them in decorators, which record metrics before delegating.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes the names of all metrics.
+const namespace = "geocoding"
+
+// registry contains all metrics, including those of the Go runtime and process.
+var registry = prometheus.NewRegistry()
+
+var (
+	cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Lookups in the location-store, by backend and result (hit, miss or error).",
+	}, []string{"backend", "result"})
+
+	storeDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "store_operation_duration_seconds",
+		Help:      "Latency of operations on the location-store, by backend and operation.",
+		Buckets:   []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1},
+	}, []string{"backend", "operation"})
+
+	upstreamRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_requests_total",
+		Help:      "Requests to the upstream geocoding API, by HTTP status (or error, if no response).",
+	}, []string{"status"})
+
+	upstreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Latency of requests to the upstream geocoding API, by HTTP status (or error, if no response).",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"status"})
+
+	throttleWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "throttle_wait_seconds",
+		Help:      "Time spent waiting to be throttled, before a request to the upstream geocoding API, by priority lane.",
+		Buckets:   []float64{.01, .1, .5, 1, 2, 5, 10, 30, 60, 300},
+	}, []string{"lane"})
+
+	throttleQueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "throttle_queue_depth",
+		Help:      "Requests currently waiting to be throttled, by priority lane.",
+	}, []string{"lane"})
+
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests, by route and method.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	}, []string{"route", "method"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		cacheRequests,
+		storeDuration,
+		upstreamRequests,
+		upstreamDuration,
+		throttleWait,
+		throttleQueueDepth,
+		httpRequests,
+		httpDuration,
+	)
+}
+
+// Handler serves all metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owenfeehan/geocoding-nominatim-cache/fetcher"
+	"github.com/owenfeehan/geocoding-nominatim-cache/location"
+	"github.com/owenfeehan/geocoding-nominatim-cache/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInstrumentStoreHitsAndMisses(t *testing.T) {
+	locStore := InstrumentStore(store.NewMemoryStore(), "test")
+
+	_, _ = locStore.Get(context.Background(), "Brussels")
+	_ = locStore.Set(context.Background(), "Brussels", []location.Location{{DisplayName: "Brussels"}})
+	_, _ = locStore.Get(context.Background(), "Brussels")
+	_, _ = locStore.Get(context.Background(), "Brussels")
+
+	assertMetric(t, cacheRequests.WithLabelValues("test", "miss"), 1)
+	assertMetric(t, cacheRequests.WithLabelValues("test", "hit"), 2)
+}
+
+// fixedFetcher returns the same error for every query.
+type fixedFetcher struct {
+	err error
+}
+
+func (f *fixedFetcher) Fetch(_ context.Context, _ fetcher.Request) ([]location.Location, error) {
+	return nil, f.err
+}
+
+func TestInstrumentFetcherStatus(t *testing.T) {
+	before := testutil.ToFloat64(upstreamRequests.WithLabelValues("429"))
+
+	_, _ = InstrumentFetcher(&fixedFetcher{err: fmt.Errorf("wrapped: %w", &fetcher.StatusError{StatusCode: 429})}).Fetch(context.Background(), fetcher.Search("A"))
+	_, _ = InstrumentFetcher(&fixedFetcher{err: errors.New("connection refused")}).Fetch(context.Background(), fetcher.Search("A"))
+
+	assertMetric(t, upstreamRequests.WithLabelValues("429"), before+1)
+	if got := testutil.ToFloat64(upstreamRequests.WithLabelValues("error")); got < 1 {
+		t.Errorf("expected at least one upstream error, got %v", got)
+	}
+}
+
+func TestThrottleObserver(t *testing.T) {
+	observer := ThrottleObserver()
+	observer.Queued(fetcher.PriorityBulk)
+	observer.Queued(fetcher.PriorityBulk)
+	observer.Queued(fetcher.PriorityInteractive)
+	observer.Dequeued(fetcher.PriorityBulk, time.Second)
+	assertMetric(t, throttleQueueDepth.WithLabelValues("bulk"), 1)
+	assertMetric(t, throttleQueueDepth.WithLabelValues("interactive"), 1)
+	observer.Dequeued(fetcher.PriorityBulk, time.Second)
+	observer.Dequeued(fetcher.PriorityInteractive, time.Second)
+}
+
+func TestMiddlewareAndHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/locations/:place", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/metrics", gin.WrapH(Handler()))
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/locations/Brussels", nil))
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	want := `geocoding_http_requests_total{method="GET",route="/locations/:place",status="200"} 1`
+	if !strings.Contains(recorder.Body.String(), want) {
+		t.Errorf("expected the metrics to contain %s", want)
+	}
+}
+
+// assertMetric asserts the value of a counter or gauge.
+func assertMetric(t *testing.T, metric prometheus.Collector, want float64) {
+	t.Helper()
+	if got := testutil.ToFloat64(metric); got != want {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
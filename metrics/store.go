@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/owenfeehan/geocoding-nominatim-cache/location"
+	"github.com/owenfeehan/geocoding-nominatim-cache/store"
+)
+
+// instrumentedStore wraps a LocationStore, recording cache hits/misses and the latency of gets and sets.
+//
+// Other methods are passed directly to the delegate.
+type instrumentedStore struct {
+	store.LocationStore
+
+	// The type of backend, used as a label
+	backend string
+}
+
+// InstrumentStore wraps a LocationStore to record metrics, labelled with the type of backend (e.g. badger or redis).
+func InstrumentStore(delegate store.LocationStore, backend string) store.LocationStore {
+	return &instrumentedStore{LocationStore: delegate, backend: backend}
+}
+
+// Get records whether the delegate's Get was a hit, a miss or failed, and its latency.
+func (s *instrumentedStore) Get(ctx context.Context, key string) ([]location.Location, error) {
+	start := time.Now()
+	locs, err := s.LocationStore.Get(ctx, key)
+	storeDuration.WithLabelValues(s.backend, "get").Observe(time.Since(start).Seconds())
+
+	switch {
+	case err != nil:
+		cacheRequests.WithLabelValues(s.backend, "error").Inc()
+	case locs != nil:
+		cacheRequests.WithLabelValues(s.backend, "hit").Inc()
+	default:
+		cacheRequests.WithLabelValues(s.backend, "miss").Inc()
+	}
+	return locs, err
+}
+
+// Set records the latency of the delegate's Set.
+func (s *instrumentedStore) Set(ctx context.Context, key string, value []location.Location) error {
+	start := time.Now()
+	err := s.LocationStore.Set(ctx, key, value)
+	storeDuration.WithLabelValues(s.backend, "set").Observe(time.Since(start).Seconds())
+	return err
+}
+
+// Assert implementation
+var _ store.LocationStore = (*instrumentedStore)(nil)
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/owenfeehan/geocoding-nominatim-cache/jobs"
+	"github.com/owenfeehan/geocoding-nominatim-cache/location"
+	"github.com/owenfeehan/geocoding-nominatim-cache/router"
+	"github.com/owenfeehan/geocoding-nominatim-cache/store"
+	"github.com/rs/zerolog/log"
+)
+
+// migrateStats counts the entries processed during a migration.
+type migrateStats struct {
+	Copied int
+	Failed int
+
+	// The number of records copied (e.g. API keys and jobs).
+	Records int
+}
+
+// runMigrate implements the migrate subcommand, copying all entries from one store to another.
+//
+// Example:
+//
+//	geocoding-nominatim-cache migrate --from badger:/path --to redis://localhost:6379 --to-redis-key-prefix geocode:
+func runMigrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	from := flags.String("from", "", "The store to copy entries from e.g. badger:/path, redis://host:6379 or memory:")
+	to := flags.String("to", "", "The store to copy entries to e.g. badger:/path, redis://host:6379 or memory:")
+	fromRedis := addRedisFlags(flags, "from-")
+	toRedis := addRedisFlags(flags, "to-")
+	dryRun := flags.Bool("dry-run", false, "Reads and counts the entries in the source store, without writing to the destination store.")
+	progressEvery := flags.Int("progress", 1000, "Reports progress after every such number of entries.")
+	debug := flags.Bool("debug", false, "Enable debug logging.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	configureLogging(*debug)
+
+	if *from == "" || *to == "" {
+		return errors.New("both --from and --to must be specified")
+	}
+
+	src, err := openStoreSpec(*from, fromRedis)
+	if err != nil {
+		return fmt.Errorf("failed to open the source store: %w", err)
+	}
+	defer closeStore(src)
+
+	dst, err := openStoreSpec(*to, toRedis)
+	if err != nil {
+		return fmt.Errorf("failed to open the destination store: %w", err)
+	}
+	defer closeStore(dst)
+
+	stats, err := migrate(context.Background(), src, dst, *dryRun, func(stats migrateStats) {
+		if *progressEvery > 0 && (stats.Copied+stats.Failed)%*progressEvery == 0 {
+			fmt.Printf("Processed %d entries (%d failed)\n", stats.Copied+stats.Failed, stats.Failed)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		fmt.Printf("Dry run: %d entries and %d records would be copied from %s to %s\n", stats.Copied, stats.Records, *from, *to)
+	} else {
+		fmt.Printf("Copied %d entries and %d records from %s to %s (%d failed)\n", stats.Copied, stats.Records, *from, *to, stats.Failed)
+	}
+	return nil
+}
+
+// migrate copies every entry in src into dst, re-keying each query through dst.BuildKey, followed by the records of
+// API keys and jobs.
+//
+// Entries that cannot be written are logged and counted, without aborting the migration. A failure to copy a record
+// aborts the migration, as a partially copied job is unusable.
+//
+// progress, if non-nil, is called after each entry is processed.
+func migrate(ctx context.Context, src, dst store.LocationStore, dryRun bool, progress func(migrateStats)) (migrateStats, error) {
+	var stats migrateStats
+
+	// Refuses before copying any entries, rather than silently dropping the records
+	srcRecords, srcOK := src.(store.RecordStore)
+	dstRecords, dstOK := dst.(store.RecordStore)
+	if srcOK && !dstOK {
+		return stats, fmt.Errorf("cannot copy the records of API keys and jobs: the destination store %w", store.ErrRecordsUnsupported)
+	}
+
+	err := src.ForEach(ctx, func(query string, locs []location.Location) error {
+		key := dst.BuildKey(query)
+		if dryRun {
+			log.Debug().Str("query", query).Str("key", key).Msg("Would copy entry")
+			stats.Copied++
+		} else if err := dst.Set(ctx, key, locs); err != nil {
+			log.Error().Err(err).Str("query", query).Msg("Failed to copy entry")
+			stats.Failed++
+		} else {
+			stats.Copied++
+		}
+
+		if progress != nil {
+			progress(stats)
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("failed to iterate over the source store: %w", err)
+	}
+
+	if srcOK {
+		if err := migrateRecords(ctx, srcRecords, dstRecords, dryRun, &stats); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// migrateRecords copies the records of API keys and jobs from src into dst, counting them in stats.
+func migrateRecords(ctx context.Context, src, dst store.RecordStore, dryRun bool, stats *migrateStats) error {
+	jobKinds, err := jobs.RecordKinds(ctx, src)
+	if err != nil {
+		return fmt.Errorf("failed to find the records of jobs: %w", err)
+	}
+
+	for _, kind := range append([]string{router.KeyRecordKind}, jobKinds...) {
+		err := src.ForEachRecord(ctx, kind, func(id string, value []byte) error {
+			if !dryRun {
+				if err := dst.SetRecord(ctx, kind, id, value); err != nil {
+					return err
+				}
+			}
+			stats.Records++
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to copy the records of kind %s: %w", kind, err)
+		}
+	}
+	return nil
+}
+
+// openStoreSpec opens a store described by a specification string, connecting to Redis as configured by redis.
+//
+// The specification is one of:
+//
+//	memory:
+//	badger:             (the default BadgerDB directory)
+//	badger:/path/to/dir
+//	redis://[user:password@]host:port[/db]
+//	rediss://[user:password@]host:port[/db]
+//	redis:host:port[,host:port...]   (the addresses of sentinels or cluster nodes, with redis-sentinel-master or redis-cluster)
+func openStoreSpec(spec string, redis *redisFlags) (store.LocationStore, error) {
+	switch {
+	case spec == "memory:":
+		return store.NewMemoryStore(), nil
+	case spec == "badger:":
+		return store.NewBadgerStore(nil)
+	case strings.HasPrefix(spec, "badger:"):
+		path := strings.TrimPrefix(spec, "badger:")
+		return store.NewBadgerStore(&path)
+	case strings.HasPrefix(spec, "redis://") || strings.HasPrefix(spec, "rediss://"):
+		return store.NewRedisStore(redis.options(spec))
+	case strings.HasPrefix(spec, "redis:"):
+		return store.NewRedisStore(redis.options(strings.TrimPrefix(spec, "redis:")))
+	default:
+		return nil, fmt.Errorf("unrecognised store %q, expected memory:, badger:[path], redis://host:port, rediss://host:port or redis:host:port[,host:port...]", spec)
+	}
+}
+
+// closeStore closes a store, logging rather than returning any error.
+func closeStore(locStore store.LocationStore) {
+	if err := locStore.Close(); err != nil {
+		log.Error().Err(err).Msg("Error closing the location-store")
+	}
+}
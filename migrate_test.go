@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/owenfeehan/geocoding-nominatim-cache/jobs"
+
+	"github.com/owenfeehan/geocoding-nominatim-cache/location"
+	"github.com/owenfeehan/geocoding-nominatim-cache/router"
+	"github.com/owenfeehan/geocoding-nominatim-cache/store"
+)
+
+func TestMigrateRekeysEntries(t *testing.T) {
+	src := createBadgerStore(t)
+	want := []location.Location{{DisplayName: testQuery}}
+	if err := src.Set(context.Background(), src.BuildKey(testQuery), want); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	dst := store.NewMemoryStore()
+	var reported int
+	stats, err := migrate(context.Background(), src, dst, false, func(migrateStats) { reported++ })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.Copied != 1 || stats.Failed != 0 || reported != 1 {
+		t.Errorf("expected 1 entry copied and reported, got %+v and %d reports", stats, reported)
+	}
+
+	// The memory store uses the query directly as key, rather than the badger prefix
+	got, err := dst.Get(context.Background(), dst.BuildKey(testQuery))
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if len(got) != 1 || got[0].DisplayName != testQuery {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestMigrateCopiesRecords(t *testing.T) {
+	ctx := context.Background()
+	src := createBadgerStore(t)
+	srcRecords := src.(store.RecordStore)
+	if err := router.SaveStoreKey(ctx, srcRecords, router.APIKey{Key: "s3cret", Name: "mobile-app"}); err != nil {
+		t.Fatal(err)
+	}
+	manager := jobs.NewManager(srcRecords, nil, time.Second, 0)
+	job, err := manager.Submit(ctx, []string{"Paris"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := store.NewMemoryStore()
+	stats, err := migrate(ctx, src, dst, false, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.Records != 3 {
+		t.Errorf("expected the records of the key, the job and its queries to be copied, got %d", stats.Records)
+	}
+
+	// The key and the job are found in the destination store
+	dstRecords := dst.(store.RecordStore)
+	if key, err := router.NewStoreKeySource(dstRecords).LookupKey(ctx, "s3cret"); err != nil || key == nil || key.Name != "mobile-app" {
+		t.Errorf("expected the API key to be copied, got %v (%v)", key, err)
+	}
+	if copied, err := jobs.NewManager(dstRecords, nil, time.Second, 0).Get(ctx, job.ID); err != nil || copied.Total != 1 {
+		t.Errorf("expected the job to be copied, got %v (%v)", copied, err)
+	}
+}
+
+func TestMigrateDryRun(t *testing.T) {
+	src := store.NewMemoryStore()
+	if err := src.Set(context.Background(), src.BuildKey(testQuery), []location.Location{{DisplayName: testQuery}}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	dst := store.NewMemoryStore()
+	stats, err := migrate(context.Background(), src, dst, true, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.Copied != 1 {
+		t.Errorf("expected 1 entry to be counted, got %d", stats.Copied)
+	}
+	if got, _ := dst.Get(context.Background(), dst.BuildKey(testQuery)); got != nil {
+		t.Errorf("expected nothing to be written in a dry run, got %v", got)
+	}
+}
+
+func TestMigrateToRedisKeyPrefix(t *testing.T) {
+	src := store.NewMemoryStore()
+	if err := src.Set(context.Background(), src.BuildKey(testQuery), []location.Location{{DisplayName: testQuery}}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	// The destination is named with the same prefix as the server would use
+	server := miniredis.RunT(t)
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	toRedis := addRedisFlags(flags, "to-")
+	if err := flags.Parse([]string{"--to-redis-key-prefix", "foo:"}); err != nil {
+		t.Fatal(err)
+	}
+	dst, err := openStoreSpec("redis://"+server.Addr(), toRedis)
+	if err != nil {
+		t.Fatalf("Failed to create Redis store: %v", err)
+	}
+	defer closeStore(dst)
+
+	if _, err := migrate(context.Background(), src, dst, false, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keys := server.Keys()
+	if len(keys) != 1 || !strings.HasPrefix(keys[0], "foo:") {
+		t.Errorf("expected a single key with the prefix foo:, got %v", keys)
+	}
+}
+
+func TestOpenStoreSpecRedisCluster(t *testing.T) {
+	server := miniredis.RunT(t)
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fromRedis := addRedisFlags(flags, "from-")
+	if err := flags.Parse([]string{"--from-redis-cluster"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The addresses of cluster nodes (or sentinels) are listed after redis:
+	locStore, err := openStoreSpec("redis:"+server.Addr(), fromRedis)
+	if err != nil {
+		t.Fatalf("Failed to create Redis store: %v", err)
+	}
+	defer closeStore(locStore)
+	if err := locStore.Set(context.Background(), locStore.BuildKey(testQuery), []location.Location{}); err != nil {
+		t.Errorf("Set failed: %v", err)
+	}
+}
+
+func TestOpenStoreSpecInvalid(t *testing.T) {
+	if _, err := openStoreSpec("mongodb://localhost", nil); err == nil {
+		t.Error("expected an error for an unrecognised store")
+	}
+}
+
+// createBadgerStore creates a badger store in a temporary directory, closed when the test ends.
+func createBadgerStore(t *testing.T) store.LocationStore {
+	locStore, err := openStoreSpec("badger:"+t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("Failed to create Badger store: %v", err)
+	}
+	t.Cleanup(func() { closeStore(locStore) })
+	return locStore
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owenfeehan/geocoding-nominatim-cache/fetcher"
+	"github.com/owenfeehan/geocoding-nominatim-cache/location"
+	"github.com/owenfeehan/geocoding-nominatim-cache/router"
+)
+
+// The Nominatim-compatible endpoints accept Nominatim's parameters, and respond in its formats, so clients of Nominatim
+// (e.g. geopy or QGIS plugins) can use the service unchanged.
+
+// defaultNominatimFormat is the output format when none is requested. Nominatim's xml format is not supported.
+const defaultNominatimFormat = "jsonv2"
+
+// nominatimFormats are the supported output formats.
+var nominatimFormats = []string{"json", "jsonv2", "geojson", "geocodejson"}
+
+// structuredSearchParams are the parameters of a structured search, as an alternative to a free-text query.
+var structuredSearchParams = []string{"amenity", "street", "city", "county", "state", "country", "postalcode"}
+
+// localisedParams are parameters forwarded to Nominatim by every endpoint, as they change its results.
+var localisedParams = []string{"accept-language"}
+
+// The number of results of a search by default, and the maximum that may be requested, as for Nominatim. Only
+// Nominatim's default number of results is fetched (and cached), so no more are ever returned.
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 40
+)
+
+// NominatimErrorResponse is an error from the Nominatim-compatible endpoints, in Nominatim's format.
+type NominatimErrorResponse struct {
+	Error NominatimError `json:"error"`
+}
+
+// NominatimError describes an error from the Nominatim-compatible endpoints.
+type NominatimError struct {
+	Code    int    `json:"code" example:"400"`
+	Message string `json:"message" example:"Parameter 'lat' must be a number"`
+}
+
+// Search handles the Nominatim-compatible /search endpoint.
+//
+// @Summary      Search for places, like Nominatim
+// @Description  searches for places by a free-text query (q) or a structured query, with Nominatim's parameters and output formats. At most 10 results are returned, as only Nominatim's default number of results is cached.
+// @Produce      json
+// @Param        q                query     string  false  "free-text query"
+// @Param        amenity          query     string  false  "name or type of a point of interest (structured query)"
+// @Param        street           query     string  false  "house number and street name (structured query)"
+// @Param        city             query     string  false  "city (structured query)"
+// @Param        county           query     string  false  "county (structured query)"
+// @Param        state            query     string  false  "state (structured query)"
+// @Param        country          query     string  false  "country (structured query)"
+// @Param        postalcode       query     string  false  "postal code (structured query)"
+// @Param        format           query     string  false  "output format"  Enums(json, jsonv2, geojson, geocodejson)  default(jsonv2)
+// @Param        limit            query     int     false  "the maximum number of results"  default(10)
+// @Param        countrycodes     query     string  false  "comma-separated ISO 3166-1 alpha-2 codes of the countries to which results are restricted e.g. us,ca"
+// @Param        viewbox          query     string  false  "the preferred area of results, as the longitude and latitude of two opposite corners: x1,y1,x2,y2"
+// @Param        bounded          query     int     false  "1 to restrict results to the viewbox"  Enums(0, 1)
+// @Param        featureType      query     string  false  "restricts results to a level of the address hierarchy"  Enums(country, state, city, settlement)
+// @Param        layer            query     string  false  "comma-separated themes to which results are restricted e.g. address,poi"
+// @Param        addressdetails   query     int     false  "1 to include the components of each address"  Enums(0, 1)
+// @Param        accept-language  query     string  false  "preferred languages of the results"
+// @Param        polygon_geojson    query   int     false  "1 to include the outline of each place as a GeoJSON geometry"  Enums(0, 1)
+// @Param        polygon_threshold  query   number  false  "simplifies outlines, so no point moves further than this many degrees"
+// @Success      200  {array}   location.JSONv2
+// @Failure      400  {object}  NominatimErrorResponse
+// @Failure      401  {object}  ErrorResponse  "missing or invalid API key (only when API keys are required)"
+// @Failure      429  {object}  ErrorResponse  "a rate-limit or quota of the client is exceeded"
+// @Header       429  {integer}  Retry-After   "seconds until the request may be retried"
+// @Failure      500  {object}  NominatimErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /search [get]
+func (a *app) Search(c *gin.Context) {
+	format, ok := nominatimFormat(c)
+	if !ok {
+		return
+	}
+	geometry, threshold, ok := nominatimGeometry(c)
+	if !ok {
+		return
+	}
+	params := c.Request.URL.Query()
+
+	limit := defaultSearchLimit
+	if value := params.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			abortNominatim(c, http.StatusBadRequest, "Parameter 'limit' must be a positive integer")
+			return
+		}
+		limit = min(parsed, maxSearchLimit)
+	}
+
+	req := fetcher.Request{Endpoint: fetcher.EndpointSearch, Params: url.Values{}}
+	query := strings.TrimSpace(params.Get("q"))
+	structured := copyParams(req.Params, params, structuredSearchParams)
+	if query != "" && structured {
+		abortNominatim(c, http.StatusBadRequest, "Structured query parameters (amenity, street, city, county, state, postalcode, country) cannot be used together with 'q' parameter")
+		return
+	} else if query != "" {
+		req.Params.Set("q", query)
+	} else if !structured {
+		abortNominatim(c, http.StatusBadRequest, "Nothing to search for")
+		return
+	}
+	filters, err := fetcher.ParseSearchFilters(params)
+	if err != nil {
+		abortNominatim(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	req = req.WithParams(filters)
+	copyParams(req.Params, params, localisedParams)
+	if geometry {
+		req = req.WithGeometry()
+	}
+
+	locs, ok := a.queryNominatim(c, req)
+	if !ok {
+		return
+	}
+	locs = simplifyLocations(locs, threshold)
+	renderNominatim(c, format, locs[:min(limit, len(locs))], req.Query(), false)
+}
+
+// Reverse handles the Nominatim-compatible /reverse endpoint.
+//
+// @Summary      Find the place at a coordinate, like Nominatim
+// @Description  finds the place nearest to a coordinate, with Nominatim's parameters and output formats. If there is none, the response is an error (with status 200), as for Nominatim.
+// @Produce      json
+// @Param        lat              query     number  true   "latitude"
+// @Param        lon              query     number  true   "longitude"
+// @Param        zoom             query     int     false  "the level of detail, from 0 (country) to 18 (building)"  default(18)
+// @Param        format           query     string  false  "output format"  Enums(json, jsonv2, geojson, geocodejson)  default(jsonv2)
+// @Param        addressdetails   query     int     false  "1 to include the components of the address"  Enums(0, 1)
+// @Param        accept-language  query     string  false  "preferred languages of the result"
+// @Param        polygon_geojson    query   int     false  "1 to include the outline of each place as a GeoJSON geometry"  Enums(0, 1)
+// @Param        polygon_threshold  query   number  false  "simplifies outlines, so no point moves further than this many degrees"
+// @Success      200  {object}  location.JSONv2
+// @Failure      400  {object}  NominatimErrorResponse
+// @Failure      401  {object}  ErrorResponse  "missing or invalid API key (only when API keys are required)"
+// @Failure      429  {object}  ErrorResponse  "a rate-limit or quota of the client is exceeded"
+// @Header       429  {integer}  Retry-After   "seconds until the request may be retried"
+// @Failure      500  {object}  NominatimErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /reverse [get]
+func (a *app) Reverse(c *gin.Context) {
+	format, ok := nominatimFormat(c)
+	if !ok {
+		return
+	}
+	geometry, threshold, ok := nominatimGeometry(c)
+	if !ok {
+		return
+	}
+	params := c.Request.URL.Query()
+
+	// Coordinates are normalised, so equivalent requests share a cache entry
+	lat, latErr := strconv.ParseFloat(params.Get("lat"), 64)
+	lon, lonErr := strconv.ParseFloat(params.Get("lon"), 64)
+	if latErr != nil || lonErr != nil || math.Abs(lat) > 90 || math.Abs(lon) > 180 {
+		abortNominatim(c, http.StatusBadRequest, "Parameters 'lat' and 'lon' must be valid coordinates")
+		return
+	}
+	zoom := 18
+	if value := params.Get("zoom"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 || parsed > 18 {
+			abortNominatim(c, http.StatusBadRequest, "Parameter 'zoom' must be an integer from 0 to 18")
+			return
+		}
+		zoom = parsed
+	}
+
+	req := fetcher.Request{Endpoint: fetcher.EndpointReverse, Params: url.Values{
+		"lat":  {strconv.FormatFloat(lat, 'f', -1, 64)},
+		"lon":  {strconv.FormatFloat(lon, 'f', -1, 64)},
+		"zoom": {strconv.Itoa(zoom)},
+	}}
+	copyParams(req.Params, params, localisedParams)
+	if geometry {
+		req = req.WithGeometry()
+	}
+
+	locs, ok := a.queryNominatim(c, req)
+	if !ok {
+		return
+	}
+	locs = simplifyLocations(locs, threshold)
+	renderNominatim(c, format, locs[:min(1, len(locs))], "", true)
+}
+
+// Lookup handles the Nominatim-compatible /lookup endpoint.
+//
+// @Summary      Look up places by OSM ID, like Nominatim
+// @Description  looks up places by their OSM IDs, each prefixed by its type (N for node, W for way or R for relation), with Nominatim's parameters and output formats. Each place is cached by its ID.
+// @Produce      json
+// @Param        osm_ids          query     string  true   "comma-separated OSM IDs (at most 50) e.g. R58004,W50637691"
+// @Param        format           query     string  false  "output format"  Enums(json, jsonv2, geojson, geocodejson)  default(jsonv2)
+// @Param        addressdetails   query     int     false  "1 to include the components of each address"  Enums(0, 1)
+// @Param        accept-language  query     string  false  "preferred languages of the results"
+// @Param        polygon_geojson    query   int     false  "1 to include the outline of each place as a GeoJSON geometry"  Enums(0, 1)
+// @Param        polygon_threshold  query   number  false  "simplifies outlines, so no point moves further than this many degrees"
+// @Success      200  {array}   location.JSONv2
+// @Failure      400  {object}  NominatimErrorResponse
+// @Failure      401  {object}  ErrorResponse  "missing or invalid API key (only when API keys are required)"
+// @Failure      429  {object}  ErrorResponse  "a rate-limit or quota of the client is exceeded"
+// @Header       429  {integer}  Retry-After   "seconds until the request may be retried"
+// @Failure      500  {object}  NominatimErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /lookup [get]
+func (a *app) Lookup(c *gin.Context) {
+	format, ok := nominatimFormat(c)
+	if !ok {
+		return
+	}
+	geometry, threshold, ok := nominatimGeometry(c)
+	if !ok {
+		return
+	}
+	params := c.Request.URL.Query()
+
+	ids, err := fetcher.ParseOSMIDs(params.Get("osm_ids"))
+	if err != nil {
+		abortNominatim(c, http.StatusBadRequest, err.Error())
+		return
+	} else if len(ids) > fetcher.MaxLookupIDs {
+		abortNominatim(c, http.StatusBadRequest, fmt.Sprintf("At most %d OSM IDs may be looked up at once", fetcher.MaxLookupIDs))
+		return
+	}
+
+	localised := url.Values{}
+	copyParams(localised, params, localisedParams)
+	if geometry {
+		localised.Set(fetcher.GeometryParam, "1")
+	}
+	locs, err := fetcher.LookupCached(c.Request.Context(), a.Store, a.Fetcher, ids, localised, router.ChargeMiss)
+	if !a.checkNominatim(c, err) {
+		return
+	}
+	renderNominatim(c, format, simplifyLocations(locs, threshold), "", false)
+}
+
+// queryNominatim retrieves the locations for a request, using cache if possible.
+//
+// If this fails, an error response is sent, and false is returned.
+func (a *app) queryNominatim(c *gin.Context, req fetcher.Request) ([]location.Location, bool) {
+	locs, err := queryLocations(c.Request.Context(), a.Store, a.Fetcher, req)
+	return locs, a.checkNominatim(c, err)
+}
+
+// checkNominatim sends an error response if err is not nil, returning true only if there was no error.
+func (a *app) checkNominatim(c *gin.Context, err error) bool {
+	var limitErr *router.LimitError
+	if errors.As(err, &limitErr) {
+		router.AbortWithLimitError(c, limitErr)
+		return false
+	} else if err != nil {
+		abortNominatim(c, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	return true
+}
+
+// nominatimFormat determines the requested output format.
+//
+// If the format is not supported, an error response is sent, and false is returned.
+func nominatimFormat(c *gin.Context) (string, bool) {
+	format := c.DefaultQuery("format", defaultNominatimFormat)
+	for _, supported := range nominatimFormats {
+		if format == supported {
+			return format, true
+		}
+	}
+	abortNominatim(c, http.StatusBadRequest, fmt.Sprintf("Parameter 'format' must be one of: %s", strings.Join(nominatimFormats, ", ")))
+	return "", false
+}
+
+// nominatimGeometry determines whether the outline of each location is requested, and by how much to simplify it.
+//
+// If the parameters are invalid, an error response is sent, and false is returned.
+func nominatimGeometry(c *gin.Context) (bool, float64, bool) {
+	geometry, threshold, err := parseGeometryParams(c.Request.URL.Query())
+	if err != nil {
+		abortNominatim(c, http.StatusBadRequest, err.Error())
+		return false, 0, false
+	}
+	return geometry, threshold, true
+}
+
+// renderNominatim responds with locations in a Nominatim output format.
+//
+// If single, the response is a single location (e.g. for a reverse lookup), or an error if there is none.
+func renderNominatim(c *gin.Context, format string, locs []location.Location, query string, single bool) {
+	if format == "geocodejson" {
+		c.JSON(http.StatusOK, location.ToGeocodeJSON(locs, query))
+		return
+	}
+
+	// The details of each address are always cached, so are removed unless requested (except in GeocodeJSON)
+	if c.Query("addressdetails") != "1" {
+		withoutAddresses := make([]location.Location, len(locs))
+		for i, loc := range locs {
+			loc.Address = nil
+			withoutAddresses[i] = loc
+		}
+		locs = withoutAddresses
+	}
+
+	switch format {
+	case "geojson":
+		c.JSON(http.StatusOK, location.ToGeoJSON(locs))
+		return
+	}
+
+	if single && len(locs) == 0 {
+		c.JSON(http.StatusOK, gin.H{"error": "Unable to geocode"})
+	} else if single && format == "jsonv2" {
+		c.JSON(http.StatusOK, location.ToJSONv2(locs)[0])
+	} else if single {
+		c.JSON(http.StatusOK, locs[0])
+	} else if format == "jsonv2" {
+		c.JSON(http.StatusOK, location.ToJSONv2(locs))
+	} else {
+		c.JSON(http.StatusOK, locs)
+	}
+}
+
+// copyParams copies the named parameters that are present and not empty, returning true if any were copied.
+func copyParams(to url.Values, from url.Values, names []string) bool {
+	copied := false
+	for _, name := range names {
+		if value := strings.TrimSpace(from.Get(name)); value != "" {
+			to.Set(name, value)
+			copied = true
+		}
+	}
+	return copied
+}
+
+// abortNominatim responds with an error in Nominatim's format.
+func abortNominatim(c *gin.Context, status int, message string) {
+	c.AbortWithStatusJSON(status, NominatimErrorResponse{Error: NominatimError{Code: status, Message: message}})
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owenfeehan/geocoding-nominatim-cache/location"
+	"github.com/owenfeehan/geocoding-nominatim-cache/store"
+)
+
+func TestSearch(t *testing.T) {
+	var fetched []string
+	a := &app{
+		Store: store.NewMemoryStore(),
+		Fetcher: &mockFetcher{fetchFunc: func(query string) ([]location.Location, error) {
+			fetched = append(fetched, query)
+			return []location.Location{
+				{DisplayName: "Brussels, Belgium", Latitude: "50.85", Longitude: "4.35", Class: "boundary", Address: map[string]string{"city": "Brussels"}},
+				{DisplayName: "Brussels, Wisconsin", Latitude: "44.73", Longitude: "-87.62", Class: "place"},
+			}, nil
+		}},
+	}
+
+	// jsonv2 by default, without the address unless requested
+	recorder := serveNominatim(a.Search, "/search?q=Brussels&limit=1")
+	var results []location.JSONv2
+	if err := json.Unmarshal(recorder.Body.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Code != http.StatusOK || len(results) != 1 || results[0].Category != "boundary" || results[0].Address != nil {
+		t.Errorf("unexpected response %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	// Other formats are rendered from the cache
+	recorder = serveNominatim(a.Search, "/search?q=Brussels&format=json&addressdetails=1")
+	if body := recorder.Body.String(); !strings.Contains(body, `"class":"boundary"`) || !strings.Contains(body, `"address":{"city":"Brussels"}`) {
+		t.Errorf("expected json with the address, got %s", body)
+	}
+	recorder = serveNominatim(a.Search, "/search?q=Brussels&format=geojson")
+	var collection location.FeatureCollection
+	if err := json.Unmarshal(recorder.Body.Bytes(), &collection); err != nil || len(collection.Features) != 2 {
+		t.Errorf("expected a feature collection, got %s", recorder.Body.String())
+	}
+	recorder = serveNominatim(a.Search, "/search?q=Brussels&format=geocodejson")
+	if body := recorder.Body.String(); !strings.Contains(body, `"geocoding":{"version":"0.1.0"`) || !strings.Contains(body, `"city":"Brussels"`) {
+		t.Errorf("expected geocodejson, got %s", body)
+	}
+
+	// A structured search is a separate request
+	serveNominatim(a.Search, "/search?city=Brussels&country=Belgium")
+	if got := strings.Join(fetched, " | "); got != "Brussels | search?city=Brussels&country=Belgium" {
+		t.Errorf("unexpected requests to Nominatim: %s", got)
+	}
+}
+
+func TestSearchInvalid(t *testing.T) {
+	a := &app{Store: store.NewMemoryStore(), Fetcher: &mockFetcher{}}
+	for _, url := range []string{
+		"/search",
+		"/search?q=Brussels&city=Brussels",
+		"/search?q=Brussels&format=xml",
+		"/search?q=Brussels&limit=0",
+		"/reverse?lat=91&lon=4.35",
+		"/reverse?lat=50.85&lon=4.35&zoom=19",
+		"/lookup?osm_ids=X123",
+	} {
+		handler := a.Search
+		if strings.HasPrefix(url, "/reverse") {
+			handler = a.Reverse
+		} else if strings.HasPrefix(url, "/lookup") {
+			handler = a.Lookup
+		}
+		recorder := serveNominatim(handler, url)
+		if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), `"code":400`) {
+			t.Errorf("expected a Nominatim error for %s, got %d: %s", url, recorder.Code, recorder.Body.String())
+		}
+	}
+}
+
+func TestReverse(t *testing.T) {
+	var fetched []string
+	a := &app{
+		Store: store.NewMemoryStore(),
+		Fetcher: &mockFetcher{fetchFunc: func(query string) ([]location.Location, error) {
+			fetched = append(fetched, query)
+			if strings.Contains(query, "lat=0&") {
+				return []location.Location{}, nil
+			}
+			return []location.Location{{DisplayName: "Grand-Place", Latitude: "50.8467", Longitude: "4.3524"}}, nil
+		}},
+	}
+
+	recorder := serveNominatim(a.Reverse, "/reverse?lat=50.8467&lon=4.35240&format=json")
+	var result location.Location
+	if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil || result.DisplayName != "Grand-Place" {
+		t.Errorf("expected a single location, got %s", recorder.Body.String())
+	}
+
+	// Equivalent coordinates share a cache entry
+	serveNominatim(a.Reverse, "/reverse?lat=50.84670&lon=4.3524&zoom=18")
+	if len(fetched) != 1 || fetched[0] != "reverse?lat=50.8467&lon=4.3524&zoom=18" {
+		t.Errorf("expected a single normalised request, got %v", fetched)
+	}
+
+	// No location is reported as an error, but with status 200
+	recorder = serveNominatim(a.Reverse, "/reverse?lat=0&lon=0")
+	if recorder.Code != http.StatusOK || recorder.Body.String() != `{"error":"Unable to geocode"}` {
+		t.Errorf("expected an error that there is no location, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestLookup(t *testing.T) {
+	var fetched string
+	a := &app{
+		Store: store.NewMemoryStore(),
+		Fetcher: &mockFetcher{fetchFunc: func(query string) ([]location.Location, error) {
+			fetched = query
+			return []location.Location{{DisplayName: "Brussels", OSMType: "relation", OSMID: 58004}}, nil
+		}},
+	}
+
+	recorder := serveNominatim(a.Lookup, "/lookup?osm_ids=r58004,%20W123")
+	if recorder.Code != http.StatusOK || fetched != "lookup?osm_ids=R58004%2CW123" {
+		t.Errorf("unexpected response %d (for request %s): %s", recorder.Code, fetched, recorder.Body.String())
+	}
+}
+
+func TestSearchFilters(t *testing.T) {
+	locStore := store.NewMemoryStore()
+	a := &app{
+		Store: locStore,
+		Fetcher: &mockFetcher{fetchFunc: func(query string) ([]location.Location, error) {
+			return []location.Location{{DisplayName: "Springfield, Illinois"}}, nil
+		}},
+	}
+
+	// Each filter is part of the key, normalised so equivalent filters share an entry
+	recorder := serveNominatim(a.Search, "/search?q=Springfield&countrycodes=US&featureType=city")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %s", recorder.Code, recorder.Body.String())
+	}
+	recorder = serveGeoJSON(createGeoJSONEngine(a), "/locations/Springfield?featureType=City&countrycodes=us", "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if count, _ := locStore.Count(context.Background()); count != 1 {
+		t.Errorf("expected one cache entry for equivalent filters, got %d", count)
+	}
+	if cached, _ := locStore.Get(context.Background(), "search?countrycodes=us&featureType=city&q=Springfield"); len(cached) != 1 {
+		t.Errorf("expected the filters in the cache key, got %v", cached)
+	}
+
+	if recorder := serveNominatim(a.Search, "/search?q=Springfield&bounded=1"); recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for bounded without a viewbox, got %d", recorder.Code)
+	}
+	if recorder := serveGeoJSON(createGeoJSONEngine(a), "/locations/Springfield?layer=roads", ""); recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid layer, got %d", recorder.Code)
+	}
+}
+
+// serveNominatim calls a Nominatim-compatible handler with a GET request for url, and records the response.
+func serveNominatim(handler gin.HandlerFunc, url string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/:endpoint", handler)
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, url, nil))
+	return recorder
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owenfeehan/geocoding-nominatim-cache/fetcher"
+	"github.com/owenfeehan/geocoding-nominatim-cache/router"
+)
+
+// LookupOSM handles the /locations/osm/:ids endpoint.
+//
+// @Summary      Get locations by OSM ID
+// @Description  gets the locations of OSM objects by their IDs, each prefixed by its type (N for node, W for way or R for relation). Each location is cached by its ID, and uncached IDs are fetched from Nominatim together, up to 50 per request. IDs without a location are omitted. With format=geojson (or Accept: application/geo+json), the locations are a GeoJSON FeatureCollection.
+// @Produce      json
+// @Produce      application/geo+json
+// @Param        ids                path    string  true   "comma-separated OSM IDs e.g. R58004,W50637691"
+// @Param        format             query   string  false  "json (default) or geojson, which takes precedence over the Accept header"  Enums(json, geojson)
+// @Param        polygon_geojson    query   int     false  "1 to include the outline of each location as a GeoJSON geometry"  Enums(0, 1)
+// @Param        polygon_threshold  query   number  false  "simplifies outlines, so no point moves further than this many degrees"
+// @Success      200  {array}   location.Location  "the locations, or a location.FeatureCollection for GeoJSON"
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse  "missing or invalid API key (only when API keys are required)"
+// @Failure      413  {object}  ErrorResponse  "too many IDs"
+// @Failure      429  {object}  ErrorResponse  "a rate-limit or quota of the client is exceeded"
+// @Header       429  {integer}  Retry-After   "seconds until the request may be retried"
+// @Failure      500  {object}  ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /locations/osm/{ids} [get]
+func (a *app) LookupOSM(c *gin.Context) {
+	ids, err := fetcher.ParseOSMIDs(c.Param("ids"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	} else if a.BatchMaxSize > 0 && len(ids) > a.BatchMaxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{Error: fmt.Sprintf("at most %d OSM IDs may be looked up at once", a.BatchMaxSize)})
+		return
+	}
+	geoJSON, ok := wantsGeoJSON(c)
+	if !ok {
+		return
+	}
+	geometry, threshold, err := parseGeometryParams(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var params url.Values
+	if geometry {
+		params = url.Values{fetcher.GeometryParam: {"1"}}
+	}
+	locs, err := fetcher.LookupCached(c.Request.Context(), a.Store, a.Fetcher, ids, params, router.ChargeMiss)
+	var limitErr *router.LimitError
+	if errors.As(err, &limitErr) {
+		router.AbortWithLimitError(c, limitErr)
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	locs = simplifyLocations(locs, threshold)
+	if geoJSON {
+		renderGeoJSON(c, locs)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, locs)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owenfeehan/geocoding-nominatim-cache/location"
+	"github.com/owenfeehan/geocoding-nominatim-cache/store"
+)
+
+func TestLookupOSM(t *testing.T) {
+	a := &app{
+		Store: store.NewMemoryStore(),
+		Fetcher: &mockFetcher{fetchFunc: func(query string) ([]location.Location, error) {
+			return []location.Location{{DisplayName: "Brussels", OSMType: "relation", OSMID: 58004}}, nil
+		}},
+		BatchMaxSize: 2,
+	}
+
+	recorder := serveLookupOSM(a, "r58004")
+	var locs []location.Location
+	if err := json.Unmarshal(recorder.Body.Bytes(), &locs); err != nil || len(locs) != 1 || locs[0].OSMRef() != "R58004" {
+		t.Errorf("unexpected response %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	for ids, want := range map[string]int{"X1": http.StatusBadRequest, "N1,N2,N3": http.StatusRequestEntityTooLarge} {
+		if recorder := serveLookupOSM(a, ids); recorder.Code != want {
+			t.Errorf("expected status %d for %s, got %d", want, ids, recorder.Code)
+		}
+	}
+}
+
+// serveLookupOSM looks up OSM IDs with the /locations/osm/:ids endpoint, and records the response.
+func serveLookupOSM(a *app, ids string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/locations/osm/:ids", a.LookupOSM)
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/locations/osm/"+ids, nil))
+	return recorder
+}
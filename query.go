@@ -1,44 +1,80 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
 	"github.com/owenfeehan/geocoding-nominatim-cache/fetcher"
 	"github.com/owenfeehan/geocoding-nominatim-cache/location"
+	"github.com/owenfeehan/geocoding-nominatim-cache/router"
 	"github.com/owenfeehan/geocoding-nominatim-cache/store"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// queryLocation retrieves a location for the given query, using cache if possible.
-func queryLocation(locStore store.LocationStore, locFetcher fetcher.LocationFetcher, query string) (location.Location, error) {
-	cacheKey := locStore.BuildKey(query)
+// errNoLocations is returned when a query has no locations.
+var errNoLocations = errors.New("no locations found for query")
 
-	// Try to get location from cache
-	loc, err := locStore.Get(cacheKey)
+// instrumentationName identifies the spans created by the application (with the global tracer-provider).
+const instrumentationName = "github.com/owenfeehan/geocoding-nominatim-cache"
+
+// queryLocation retrieves the first location for the given request, using cache if possible.
+func queryLocation(ctx context.Context, locStore store.LocationStore, locFetcher fetcher.LocationFetcher, req fetcher.Request) (location.Location, error) {
+	loc, err := queryLocations(ctx, locStore, locFetcher, req)
 	if err != nil {
-		return location.Location{}, fmt.Errorf("failed to retrieve from the cache: %w", err)
-	} else if loc != nil {
-		// Successful cache hit
-		return extractFirstLocation(loc, query)
+		return location.Location{}, err
+	}
+	return extractFirstLocation(loc, req.Query())
+}
+
+// queryLocations retrieves the locations for the given request, using cache if possible.
+func queryLocations(ctx context.Context, locStore store.LocationStore, locFetcher fetcher.LocationFetcher, req fetcher.Request) ([]location.Location, error) {
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, "queryLocation", trace.WithAttributes(attribute.String("query", req.Query())))
+	defer span.End()
+
+	// Try to get location from cache
+	loc, err := lookupCached(ctx, locStore, req)
+	if err != nil || loc != nil {
+		return loc, err
 	}
 
 	// If not cached, fetch from Nominatim API
-	loc, err = locFetcher.Fetch(query)
+	return fetchAndCache(ctx, locStore, locFetcher, req)
+}
+
+// lookupCached retrieves the cached locations for the given request, or nil if it is not cached.
+func lookupCached(ctx context.Context, locStore store.LocationStore, req fetcher.Request) ([]location.Location, error) {
+	loc, err := locStore.Get(ctx, locStore.BuildKey(req.Key()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve from the cache: %w", err)
+	}
+	return loc, nil
+}
+
+// fetchAndCache fetches the locations for the given request from Nominatim (if within the client's limits), and caches them.
+func fetchAndCache(ctx context.Context, locStore store.LocationStore, locFetcher fetcher.LocationFetcher, req fetcher.Request) ([]location.Location, error) {
+	if err := router.ChargeMiss(ctx); err != nil {
+		return nil, err
+	}
+
+	loc, err := locFetcher.Fetch(ctx, req)
 	if err != nil {
-		return location.Location{}, fmt.Errorf("failed to fetch location: %w", err)
+		return nil, fmt.Errorf("failed to fetch location: %w", err)
 	}
 
 	// Cache the result
-	if err := locStore.Set(cacheKey, loc); err != nil {
+	if err := locStore.Set(ctx, locStore.BuildKey(req.Key()), loc); err != nil {
 		fmt.Println("Cache Error, could not cache: ", err)
 	}
-
-	return extractFirstLocation(loc, query)
+	return loc, nil
 }
 
 // extractFirstLocation returns the first location from the slice or an error if empty.
 func extractFirstLocation(data []location.Location, query string) (location.Location, error) {
 	if len(data) == 0 {
-		return location.Location{}, fmt.Errorf("no locations found for query: %s", query)
+		return location.Location{}, fmt.Errorf("%w: %s", errNoLocations, query)
 	}
 	return data[0], nil
 }
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/owenfeehan/geocoding-nominatim-cache/fetcher"
@@ -11,21 +15,38 @@ import (
 
 const testQuery = "Brussels"
 
+// testRequest searches for testQuery.
+var testRequest = fetcher.Search(testQuery)
+
 type mockStore struct {
 	store.LocationStore
-	getFunc func(string) ([]location.Location, error)
-	setFunc func(string, []location.Location) error
+	getFunc   func(string) ([]location.Location, error)
+	setFunc   func(string, []location.Location) error
+	pingFunc  func(context.Context) error
+	countFunc func(context.Context) (int64, error)
 }
 
-func (m *mockStore) Get(key string) ([]location.Location, error) {
+func (m *mockStore) Get(_ context.Context, key string) ([]location.Location, error) {
 	return m.getFunc(key)
 }
-func (m *mockStore) Set(key string, locs []location.Location) error {
+func (m *mockStore) Set(_ context.Context, key string, locs []location.Location) error {
 	if m.setFunc != nil {
 		return m.setFunc(key, locs)
 	}
 	return nil
 }
+func (m *mockStore) Ping(ctx context.Context) error {
+	if m.pingFunc != nil {
+		return m.pingFunc(ctx)
+	}
+	return nil
+}
+func (m *mockStore) Count(ctx context.Context) (int64, error) {
+	if m.countFunc != nil {
+		return m.countFunc(ctx)
+	}
+	return 0, nil
+}
 func (m *mockStore) BuildKey(query string) string { return query }
 func (m *mockStore) Close() error                 { return nil }
 
@@ -33,8 +54,8 @@ type mockFetcher struct {
 	fetchFunc func(string) ([]location.Location, error)
 }
 
-func (m *mockFetcher) Fetch(query string) ([]location.Location, error) {
-	return m.fetchFunc(query)
+func (m *mockFetcher) Fetch(_ context.Context, req fetcher.Request) ([]location.Location, error) {
+	return m.fetchFunc(req.Query())
 }
 
 func TestQueryLocationCacheHit(t *testing.T) {
@@ -50,7 +71,7 @@ func TestQueryLocationCacheHit(t *testing.T) {
 			return nil, nil
 		},
 	}
-	got, err := queryLocation(store, fetcher, testQuery)
+	got, err := queryLocation(context.Background(), store, fetcher, testRequest)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -77,7 +98,7 @@ func TestQueryLocationCacheMissAndFetch(t *testing.T) {
 			return []location.Location{want}, nil
 		},
 	}
-	got, err := queryLocation(store, fetcher, testQuery)
+	got, err := queryLocation(context.Background(), store, fetcher, testRequest)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -97,7 +118,7 @@ func TestQueryLocationErrorCases(t *testing.T) {
 		},
 	}
 	fetcher := &mockFetcher{}
-	_, err := queryLocation(store, fetcher, testQuery)
+	_, err := queryLocation(context.Background(), store, fetcher, testRequest)
 	if err == nil || err.Error() != "failed to retrieve from the cache: "+storeErr.Error() {
 		t.Errorf("expected store error, got %v", err)
 	}
@@ -113,7 +134,7 @@ func TestQueryLocationErrorCases(t *testing.T) {
 			return nil, fetchErr
 		},
 	}
-	_, err = queryLocation(store, fetcher, testQuery)
+	_, err = queryLocation(context.Background(), store, fetcher, testRequest)
 	if err == nil || err.Error() != "failed to fetch location: "+fetchErr.Error() {
 		t.Errorf("expected fetch error, got %v", err)
 	}
@@ -130,7 +151,7 @@ func TestQueryLocationErrorCases(t *testing.T) {
 			return nil, nil
 		},
 	}
-	_, err = queryLocation(store, fetcher, testQuery)
+	_, err = queryLocation(context.Background(), store, fetcher, testRequest)
 	if err == nil || err.Error() != "no locations found for query: "+testQuery {
 		t.Errorf("expected no locations found error, got %v", err)
 	}
@@ -139,3 +160,20 @@ func TestQueryLocationErrorCases(t *testing.T) {
 // Assert implementation of mocked interfaces.
 var _ store.LocationStore = (*mockStore)(nil)
 var _ fetcher.LocationFetcher = (*mockFetcher)(nil)
+
+func TestForwardGeocodeError(t *testing.T) {
+	engine := createGeoJSONEngine(&app{
+		Store: store.NewMemoryStore(),
+		Fetcher: &mockFetcher{fetchFunc: func(string) ([]location.Location, error) {
+			return nil, errors.New("upstream unavailable")
+		}},
+	})
+
+	recorder := serveGeoJSON(engine, "/locations/Brussels", "")
+	var response ErrorResponse
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", recorder.Code)
+	} else if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil || !strings.Contains(response.Error, "upstream unavailable") {
+		t.Errorf("expected the error message in the response, got %s", recorder.Body.String())
+	}
+}
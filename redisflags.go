@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"time"
+
+	"github.com/owenfeehan/geocoding-nominatim-cache/store"
+)
+
+// redisFlags are the flags configuring a connection to Redis (other than its address), shared by the server and the
+// subcommands, so each connects to Redis (and names its keys) in the same way.
+type redisFlags struct {
+	sentinelMaster   *string
+	sentinelPassword *string
+	cluster          *bool
+	username         *string
+	password         *string
+	db               *int
+	tls              *bool
+	tlsCA            *string
+	tlsCert          *string
+	tlsKey           *string
+	poolSize         *int
+	dialTimeout      *time.Duration
+	timeout          *time.Duration
+	keyPrefix        *string
+	passwordEnvs     bool
+}
+
+// addRedisFlags defines the flags configuring a connection to Redis, each named with prefix (e.g. "to-" for
+// --to-redis-key-prefix).
+//
+// Without a prefix, passwords default to environment variables, as there is then a single connection to Redis.
+func addRedisFlags(flags *flag.FlagSet, prefix string) *redisFlags {
+	f := &redisFlags{passwordEnvs: prefix == ""}
+	passwordHelp := "The password for Redis authentication."
+	sentinelPasswordHelp := "The password for the Redis sentinels, if different from the Redis password."
+	if f.passwordEnvs {
+		passwordHelp += " Defaults to the REDIS_PASSWORD environment variable."
+		sentinelPasswordHelp += " Defaults to the REDIS_SENTINEL_PASSWORD environment variable."
+	}
+
+	f.sentinelMaster = flags.String(prefix+"redis-sentinel-master", "", "Connects via Redis Sentinel to the master with this name. The address of Redis is then a comma-separated list of the sentinel addresses.")
+	f.sentinelPassword = flags.String(prefix+"redis-sentinel-password", "", sentinelPasswordHelp)
+	f.cluster = flags.Bool(prefix+"redis-cluster", false, "Connects to a Redis Cluster. The address of Redis is then a comma-separated list of (some of) the cluster node addresses.")
+	f.username = flags.String(prefix+"redis-username", "", "The username for Redis ACL authentication.")
+	f.password = flags.String(prefix+"redis-password", "", passwordHelp)
+	f.db = flags.Int(prefix+"redis-db", 0, "The index of the Redis database to select. If zero, the database in the Redis URL (if any) is used.")
+	f.tls = flags.Bool(prefix+"redis-tls", false, "Connects to Redis with TLS. This is implied by a rediss:// URL or any of the TLS files.")
+	f.tlsCA = flags.String(prefix+"redis-tls-ca", "", "PEM file of the certificate-authorities to trust for the Redis server certificate. If not set, the system pool is used.")
+	f.tlsCert = flags.String(prefix+"redis-tls-cert", "", "PEM file of a client certificate, for Redis servers requiring mutual TLS.")
+	f.tlsKey = flags.String(prefix+"redis-tls-key", "", "PEM file of the key for the client certificate.")
+	f.poolSize = flags.Int(prefix+"redis-pool-size", 0, "The maximum number of connections to Redis. If zero, 10 per CPU are used.")
+	f.dialTimeout = flags.Duration(prefix+"redis-dial-timeout", 0, "The timeout for connecting to Redis. If zero, 5s is used.")
+	f.timeout = flags.Duration(prefix+"redis-timeout", 0, "The timeout for each read or write to Redis. If zero, 3s is used.")
+	f.keyPrefix = flags.String(prefix+"redis-key-prefix", "geocode:", "Prepended to each query to form a Redis key.")
+	return f
+}
+
+// options builds the options to connect to Redis at address, from the parsed flags.
+//
+// Passwords are read from the environment only now, so they are never shown as defaults in the usage.
+func (f *redisFlags) options(address string) store.RedisOptions {
+	password, sentinelPassword := *f.password, *f.sentinelPassword
+	if f.passwordEnvs {
+		if password == "" {
+			password = os.Getenv("REDIS_PASSWORD")
+		}
+		if sentinelPassword == "" {
+			sentinelPassword = os.Getenv("REDIS_SENTINEL_PASSWORD")
+		}
+	}
+	return store.RedisOptions{
+		Address:          address,
+		MasterName:       *f.sentinelMaster,
+		SentinelPassword: sentinelPassword,
+		Cluster:          *f.cluster,
+		Username:         *f.username,
+		Password:         password,
+		DB:               *f.db,
+		TLS:              *f.tls,
+		TLSCAFile:        *f.tlsCA,
+		TLSCertFile:      *f.tlsCert,
+		TLSKeyFile:       *f.tlsKey,
+		PoolSize:         *f.poolSize,
+		DialTimeout:      *f.dialTimeout,
+		ReadTimeout:      *f.timeout,
+		WriteTimeout:     *f.timeout,
+		KeyPrefix:        *f.keyPrefix,
+	}
+}
@@ -0,0 +1,224 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owenfeehan/geocoding-nominatim-cache/fetcher"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/time/rate"
+)
+
+// APIKeyHeader is the header in which clients present their API key (alternatively as an Authorization bearer token).
+const APIKeyHeader = "X-API-Key"
+
+// KeyNameContextKey is the key in the Gin context of the name of the authenticated API key.
+const KeyNameContextKey = "apiKeyName"
+
+// adminContextKey is the key in the Gin context of whether the authenticated API key is an admin key.
+const adminContextKey = "apiKeyAdmin"
+
+// clientKeyContextKey is the key in the Gin context of the authenticated API key, as part of a Client.
+const clientKeyContextKey = "apiKeyClient"
+
+// LimitError is returned when a client exceeds a rate-limit or quota.
+type LimitError struct {
+	// Describes the limit that was exceeded.
+	Message string
+
+	// How long until the client may retry.
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string {
+	return e.Message
+}
+
+// AbortWithLimitError responds with 429 Too Many Requests and a Retry-After header (in whole seconds).
+func AbortWithLimitError(c *gin.Context, err *LimitError) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(err.RetryAfter.Seconds()))))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+}
+
+// missLimiter limits the cache misses of a client.
+type missLimiter interface {
+	// reserveMiss counts a cache miss, or returns a LimitError if this would exceed a limit.
+	//
+	// If successful, undo reverts the count, e.g. if another limiter then refuses the miss.
+	reserveMiss(now time.Time) (undo func(), err *LimitError)
+}
+
+// missLimitersKey is the key in a request's context of the missLimiters that apply to it.
+type missLimitersKey struct{}
+
+// withMissLimiter adds a limiter on cache misses to the context of a request.
+func withMissLimiter(c *gin.Context, limiter missLimiter) {
+	c.Request = c.Request.WithContext(appendMissLimiter(c.Request.Context(), limiter))
+}
+
+// appendMissLimiter adds a limiter on cache misses to ctx.
+func appendMissLimiter(ctx context.Context, limiter missLimiter) context.Context {
+	limiters, _ := ctx.Value(missLimitersKey{}).([]missLimiter)
+	limiters = append(limiters[:len(limiters):len(limiters)], limiter)
+	return context.WithValue(ctx, missLimitersKey{}, limiters)
+}
+
+// ChargeMiss counts a cache miss against every limit applying to the request with ctx, before fetching from Nominatim.
+//
+// A LimitError is returned (and nothing counted), if any limit would be exceeded.
+func ChargeMiss(ctx context.Context) error {
+	limiters, _ := ctx.Value(missLimitersKey{}).([]missLimiter)
+	now := time.Now()
+	var undos []func()
+	for _, limiter := range limiters {
+		undo, err := limiter.reserveMiss(now)
+		if err != nil {
+			for _, undo := range undos {
+				undo()
+			}
+			return err
+		}
+		undos = append(undos, undo)
+	}
+	return nil
+}
+
+// Authenticate requires a valid API key for each request, and limits the cache misses of each key.
+//
+// Requests without a key, or with an unknown key, are refused with 401 Unauthorized.
+func Authenticate(keys KeySource) gin.HandlerFunc {
+	return NewLimiters(RateLimits{}).Authenticate(keys)
+}
+
+// Authenticate requires a valid API key for each request, and limits the cache misses of each key, as counted in l.
+func (l *Limiters) Authenticate(keys KeySource) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		secret := presentedKey(c.Request)
+		if secret == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "an API key is required, in the " + APIKeyHeader + " header"})
+			return
+		}
+
+		key, err := keys.LookupKey(c.Request.Context(), secret)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to look up API key")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to look up the API key"})
+			return
+		} else if key == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid API key"})
+			return
+		}
+
+		// The usage is retained across requests (by the hash, to avoid holding secrets), updating its limits if the key changes
+		id := HashKey(secret)
+		usage := l.keyUsage(id, *key, true)
+
+		c.Set(KeyNameContextKey, key.Name)
+		c.Set(adminContextKey, key.Admin)
+		c.Set(clientKeyContextKey, clientKey{id: id, key: *key})
+		withMissLimiter(c, usage)
+		if priority, _ := key.Priority(); priority == fetcher.PriorityBulk {
+			withBulkPriority(c)
+		}
+		c.Next()
+	}
+}
+
+// RequireAdmin refuses requests whose API key is not an admin key with 403 Forbidden. It must follow Authenticate.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool(adminContextKey) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "an admin API key is required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// presentedKey extracts the API key from the X-API-Key header, or else an Authorization bearer token.
+func presentedKey(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// keyUsage limits the cache misses of a single API key, by a rate-limit and a daily quota.
+type keyUsage struct {
+	mu sync.Mutex
+
+	name string
+
+	// Limits the rate of misses, or nil if unlimited.
+	limiter *rate.Limiter
+
+	// The maximum misses per day, or zero if unlimited.
+	dailyMisses int
+
+	// The day (UTC) on which misses were counted, and the count.
+	day    string
+	misses int
+}
+
+// configure applies the limits of key, preserving any usage so far.
+func (u *keyUsage) configure(key APIKey) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.name = key.Name
+	u.dailyMisses = key.DailyMisses
+	if key.MissesPerMinute <= 0 {
+		u.limiter = nil
+	} else if limit := rate.Limit(float64(key.MissesPerMinute) / 60); u.limiter == nil {
+		u.limiter = rate.NewLimiter(limit, key.MissesPerMinute)
+	} else if u.limiter.Limit() != limit {
+		u.limiter.SetLimit(limit)
+		u.limiter.SetBurst(key.MissesPerMinute)
+	}
+}
+
+func (u *keyUsage) reserveMiss(now time.Time) (func(), *LimitError) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	day := now.UTC().Format(time.DateOnly)
+	if day != u.day {
+		u.day = day
+		u.misses = 0
+	}
+	if u.dailyMisses > 0 && u.misses >= u.dailyMisses {
+		midnight := now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+		return nil, &LimitError{
+			Message:    fmt.Sprintf("the daily quota of %d uncached requests for API key %s is exhausted", u.dailyMisses, u.name),
+			RetryAfter: midnight.Sub(now),
+		}
+	}
+
+	limiter := u.limiter
+	reservation, err := reserve(limiter, now, func() string {
+		return fmt.Sprintf("the rate-limit of %d uncached requests per minute for API key %s is exceeded", limiter.Burst(), u.name)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	u.misses++
+	return func() {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		if u.misses > 0 {
+			u.misses--
+		}
+		cancelReservation(reservation, now)
+	}, nil
+}
@@ -0,0 +1,196 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owenfeehan/geocoding-nominatim-cache/store"
+)
+
+func TestAuthenticate(t *testing.T) {
+	keys := loadTestKeys(t, `[{"key": "s3cret", "name": "test"}]`)
+	engine := createTestEngine(Authenticate(keys))
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   int
+	}{
+		{"missing key", "", "", http.StatusUnauthorized},
+		{"invalid key", APIKeyHeader, "wrong", http.StatusUnauthorized},
+		{"valid key", APIKeyHeader, "s3cret", http.StatusOK},
+		{"valid bearer token", "Authorization", "Bearer s3cret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serveTestRequest(engine, tt.header, tt.value).Code; got != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAuthenticateDailyQuota(t *testing.T) {
+	keys := loadTestKeys(t, `[{"key": "s3cret", "name": "test", "daily_misses": 2}]`)
+	engine := createTestEngine(Authenticate(keys))
+
+	for i := range 2 {
+		if got := serveTestRequest(engine, APIKeyHeader, "s3cret").Code; got != http.StatusOK {
+			t.Fatalf("expected miss %d within the quota, got status %d", i, got)
+		}
+	}
+
+	recorder := serveTestRequest(engine, APIKeyHeader, "s3cret")
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429 after the quota, got %d", recorder.Code)
+	}
+	if recorder.Header().Get("Retry-After") == "" {
+		t.Error("expected a Retry-After header")
+	}
+}
+
+func TestAdminBackupRequiresAdminKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	keys := loadTestKeys(t, `[{"key": "s3cret", "name": "test"}, {"key": "adm1n", "name": "admin", "admin": true}]`)
+	configureRouter(engine, Routes{Auth: Authenticate(keys), Backup: func(c *gin.Context) { c.Status(http.StatusOK) }})
+
+	tests := []struct {
+		name string
+		key  string
+		want int
+	}{
+		{"missing key", "", http.StatusUnauthorized},
+		{"not an admin key", "s3cret", http.StatusForbidden},
+		{"admin key", "adm1n", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/admin/backup", nil)
+			if tt.key != "" {
+				req.Header.Set(APIKeyHeader, tt.key)
+			}
+			recorder := httptest.NewRecorder()
+			engine.ServeHTTP(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, recorder.Code)
+			}
+		})
+	}
+}
+
+func TestKeyUsageRateLimit(t *testing.T) {
+	usage := &keyUsage{}
+	usage.configure(APIKey{Name: "test", MissesPerMinute: 1})
+
+	now := time.Now()
+	if _, err := usage.reserveMiss(now); err != nil {
+		t.Fatalf("expected the first miss to be allowed, got %v", err)
+	}
+	_, err := usage.reserveMiss(now)
+	if err == nil {
+		t.Fatal("expected the second miss to exceed the rate-limit")
+	}
+	if err.RetryAfter <= 0 || err.RetryAfter > time.Minute {
+		t.Errorf("expected to retry within a minute, got %v", err.RetryAfter)
+	}
+}
+
+func TestChargeMissUndoes(t *testing.T) {
+	first := &keyUsage{}
+	first.configure(APIKey{Name: "first", DailyMisses: 1})
+	second := &keyUsage{}
+	second.configure(APIKey{Name: "second", DailyMisses: 1})
+	if _, err := second.reserveMiss(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second limiter refuses, so the first must not count the miss
+	ctx := context.WithValue(context.Background(), missLimitersKey{}, []missLimiter{first, second})
+	var limitErr *LimitError
+	if err := ChargeMiss(ctx); !errors.As(err, &limitErr) {
+		t.Fatalf("expected a LimitError, got %v", err)
+	}
+	if first.misses != 0 {
+		t.Errorf("expected the refused miss to be undone, got %d misses", first.misses)
+	}
+
+	if err := ChargeMiss(context.Background()); err != nil {
+		t.Errorf("expected no limits without any limiters, got %v", err)
+	}
+}
+
+func TestStoreKeySource(t *testing.T) {
+	records := store.NewMemoryStore().(store.RecordStore)
+	ctx := context.Background()
+	if err := SaveStoreKey(ctx, records, APIKey{Key: "s3cret", Name: "test", DailyMisses: 5}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The secret is not stored
+	value, err := records.GetRecord(ctx, KeyRecordKind, HashKey("s3cret"))
+	if err != nil || value == nil {
+		t.Fatalf("expected a record for the key, got %v", err)
+	}
+
+	keys := NewStoreKeySource(records)
+	key, err := keys.LookupKey(ctx, "s3cret")
+	if err != nil || key == nil || key.Name != "test" || key.DailyMisses != 5 || key.Key != "" {
+		t.Errorf("unexpected key %+v (%v)", key, err)
+	}
+	if key, _ := keys.LookupKey(ctx, "wrong"); key != nil {
+		t.Errorf("expected no key for a wrong secret, got %+v", key)
+	}
+}
+
+// Writes keys to a file, and loads them.
+func loadTestKeys(t *testing.T, content string) KeySource {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := LoadKeyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+// Creates an engine whose handler always charges a miss, after the given middleware.
+func createTestEngine(middleware ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(middleware...)
+	engine.GET("/test", chargeMissHandler)
+	return engine
+}
+
+// chargeMissHandler handles a request as a cache miss.
+func chargeMissHandler(c *gin.Context) {
+	var limitErr *LimitError
+	if err := ChargeMiss(c.Request.Context()); errors.As(err, &limitErr) {
+		AbortWithLimitError(c, limitErr)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// Serves a request to the test endpoint, with an optional header.
+func serveTestRequest(engine *gin.Engine, header string, value string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, req)
+	return recorder
+}
@@ -0,0 +1,72 @@
+package router
+
+import (
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// Defaults for the CORS options, suitable for browser clients of the geocoding endpoints.
+var (
+	defaultCORSMethods = []string{"GET", "POST", "OPTIONS"}
+	defaultCORSHeaders = []string{"Origin", "Accept", "Content-Type", "Authorization", APIKeyHeader}
+)
+
+// defaultCORSMaxAge is how long browsers may cache the result of a preflight request, by default.
+const defaultCORSMaxAge = 12 * time.Hour
+
+// CORSOptions configures Cross-Origin Resource Sharing, so browser clients on other origins may call the service.
+//
+// The zero value disables CORS, so browsers only permit requests from the service's own origin.
+type CORSOptions struct {
+	// The origins permitted to call the service e.g. https://maps.example.com, or * for any origin.
+	// If empty, CORS is disabled.
+	AllowedOrigins []string
+
+	// The methods permitted in requests. If empty, GET, POST and OPTIONS are permitted.
+	AllowedMethods []string
+
+	// The headers permitted in requests. If empty, common headers and the API key header are permitted.
+	AllowedHeaders []string
+
+	// How long browsers may cache the result of a preflight request. If zero, 12 hours is used.
+	MaxAge time.Duration
+}
+
+// corsMiddleware creates the middleware for the CORS options, or returns nil if CORS is disabled.
+func corsMiddleware(opts CORSOptions) (gin.HandlerFunc, error) {
+	if len(opts.AllowedOrigins) == 0 {
+		return nil, nil
+	}
+
+	config := cors.Config{
+		AllowMethods: withDefault(opts.AllowedMethods, defaultCORSMethods),
+		AllowHeaders: withDefault(opts.AllowedHeaders, defaultCORSHeaders),
+		// Allows browser clients to read when to retry, after being rate-limited
+		ExposeHeaders: []string{"Retry-After"},
+		MaxAge:        opts.MaxAge,
+	}
+	if config.MaxAge == 0 {
+		config.MaxAge = defaultCORSMaxAge
+	}
+
+	if len(opts.AllowedOrigins) == 1 && opts.AllowedOrigins[0] == "*" {
+		config.AllowAllOrigins = true
+	} else {
+		config.AllowOrigins = opts.AllowedOrigins
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return cors.New(config), nil
+}
+
+// withDefault returns values, or defaults if values is empty.
+func withDefault(values []string, defaults []string) []string {
+	if len(values) == 0 {
+		return defaults
+	}
+	return values
+}
@@ -0,0 +1,59 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCORSPreflightWithoutAPIKey(t *testing.T) {
+	router, err := createRouter("", CORSOptions{AllowedOrigins: []string{"https://maps.example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := loadTestKeys(t, `[{"key": "s3cret", "name": "test"}]`)
+	configureRouter(router, Routes{Auth: Authenticate(keys), ForwardGeocode: func(c *gin.Context) { c.Status(http.StatusOK) }})
+
+	req := httptest.NewRequest(http.MethodOptions, "/locations/Brussels", nil)
+	req.Header.Set("Origin", "https://maps.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	req.Header.Set("Access-Control-Request-Headers", APIKeyHeader)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusNoContent {
+		t.Errorf("expected status 204 for the preflight request, got %d", recorder.Code)
+	}
+	if got := recorder.Header().Get("Access-Control-Allow-Origin"); got != "https://maps.example.com" {
+		t.Errorf("expected the origin to be allowed, got %q", got)
+	}
+}
+
+func TestCORSDisallowedOrigin(t *testing.T) {
+	router, err := createRouter("", CORSOptions{AllowedOrigins: []string{"https://maps.example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.GET("/test", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 for a disallowed origin, got %d", recorder.Code)
+	}
+}
+
+func TestCORSDisabled(t *testing.T) {
+	handler, err := corsMiddleware(CORSOptions{})
+	if handler != nil || err != nil {
+		t.Errorf("expected CORS to be disabled without origins, got %v", err)
+	}
+	if _, err := corsMiddleware(CORSOptions{AllowedOrigins: []string{"not-a-url"}}); err == nil {
+		t.Error("expected an error for an invalid origin")
+	}
+}
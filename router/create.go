@@ -14,8 +14,11 @@ import (
 //
 // proxyList is a comma-separated list of trusted proxy IPs or CIDRs.
 //
+// corsOpts configures Cross-Origin Resource Sharing, which is applied before any other middleware (e.g. authentication),
+// so preflight requests are answered without an API key.
+//
 // It will exit with a fatal error if setting the proxy list fails.
-func createRouter(proxyList string) (*gin.Engine, error) {
+func createRouter(proxyList string, corsOpts CORSOptions) (*gin.Engine, error) {
 	// Write prettified console output with timestamps to stdout
 	consoleWriter := zerolog.ConsoleWriter{
 		Out:        os.Stdout,
@@ -37,6 +40,14 @@ func createRouter(proxyList string) (*gin.Engine, error) {
 	router.Use(gin.Logger()) // writes INFO-level request logs
 	router.Use(gin.Recovery())
 
+	corsHandler, err := corsMiddleware(corsOpts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CORS options: %w", err)
+	}
+	if corsHandler != nil {
+		router.Use(corsHandler)
+	}
+
 	if err := configureTrustedProxies(router, proxyList); err != nil {
 		return nil, fmt.Errorf("failed to configure trusted proxies: %s for %w", proxyList, err)
 	}
@@ -0,0 +1,139 @@
+package router
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/owenfeehan/geocoding-nominatim-cache/fetcher"
+	"github.com/owenfeehan/geocoding-nominatim-cache/store"
+)
+
+// KeyRecordKind is the kind of the records that hold API keys in a store.
+const KeyRecordKind = "apikey"
+
+// APIKey describes a client permitted to call the geocoding endpoints, and the limits on its cache misses.
+//
+// Only cache misses are limited, as these alone consume the shared budget of requests to Nominatim.
+type APIKey struct {
+	// The secret presented by the client. It is omitted for keys held in a store, which are identified by a hash instead.
+	Key string `json:"key,omitempty"`
+
+	// Identifies the client e.g. in logs.
+	Name string `json:"name"`
+
+	// The maximum number of cache misses per minute. If zero, the rate is unlimited.
+	MissesPerMinute int `json:"misses_per_minute,omitempty"`
+
+	// The maximum number of cache misses per day (UTC). If zero, there is no daily quota.
+	DailyMisses int `json:"daily_misses,omitempty"`
+
+	// The priority of the key's cache misses, interactive or bulk. If empty, they are interactive.
+	Class string `json:"class,omitempty"`
+
+	// Whether the key may also call the admin endpoints e.g. to write a backup.
+	Admin bool `json:"admin,omitempty"`
+}
+
+// KeySource looks up the API keys presented by clients.
+type KeySource interface {
+	// LookupKey returns the API key with the given secret, or nil if there is none.
+	LookupKey(ctx context.Context, secret string) (*APIKey, error)
+}
+
+// HashKey derives the identifier of the record holding an API key, so the secret itself is never stored.
+func HashKey(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+// fileKeys holds API keys loaded from a file, indexed by their secret.
+type fileKeys map[string]APIKey
+
+// LoadKeyFile loads API keys from a JSON file, containing an array of objects like APIKey.
+//
+// Example:
+//
+//	[{"key": "s3cret", "name": "mobile-app", "misses_per_minute": 10, "daily_misses": 1000, "class": "interactive"}]
+func LoadKeyFile(path string) (KeySource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read API keys file: %w", err)
+	}
+
+	var list []APIKey
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("cannot parse API keys file %s: %w", path, err)
+	}
+
+	keys := make(fileKeys, len(list))
+	for _, key := range list {
+		if key.Key == "" || key.Name == "" {
+			return nil, fmt.Errorf("every API key in %s requires a key and a name", path)
+		} else if _, exists := keys[key.Key]; exists {
+			return nil, fmt.Errorf("API key %s occurs more than once in %s", key.Name, path)
+		} else if _, err := key.Priority(); err != nil {
+			return nil, fmt.Errorf("API key %s in %s has an invalid class: %w", key.Name, path, err)
+		}
+		keys[key.Key] = key
+	}
+	return keys, nil
+}
+
+// Priority is the priority of the key's cache misses, by its class.
+func (k APIKey) Priority() (fetcher.Priority, error) {
+	if k.Class == "" {
+		return fetcher.PriorityInteractive, nil
+	}
+	return fetcher.ParsePriority(k.Class)
+}
+
+func (k fileKeys) LookupKey(_ context.Context, secret string) (*APIKey, error) {
+	if key, ok := k[secret]; ok {
+		return &key, nil
+	}
+	return nil, nil
+}
+
+// storeKeys looks up API keys held as records in a store, so they can be added or revoked without a restart (of a
+// service using Redis, as BadgerDB can only be opened by one process).
+type storeKeys struct {
+	records store.RecordStore
+}
+
+// NewStoreKeySource looks up API keys held as records in a store (as written by SaveStoreKey).
+func NewStoreKeySource(records store.RecordStore) KeySource {
+	return &storeKeys{records: records}
+}
+
+func (k *storeKeys) LookupKey(ctx context.Context, secret string) (*APIKey, error) {
+	value, err := k.records.GetRecord(ctx, KeyRecordKind, HashKey(secret))
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	var key APIKey
+	if err := json.Unmarshal(value, &key); err != nil {
+		return nil, fmt.Errorf("cannot parse API key record: %w", err)
+	}
+	return &key, nil
+}
+
+// SaveStoreKey adds (or replaces) an API key in a store, identified by the hash of its secret.
+func SaveStoreKey(ctx context.Context, records store.RecordStore, key APIKey) error {
+	if key.Key == "" || key.Name == "" {
+		return errors.New("an API key requires a key and a name")
+	}
+
+	id := HashKey(key.Key)
+	key.Key = ""
+	value, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	return records.SetRecord(ctx, KeyRecordKind, id, value)
+}
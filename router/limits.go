@@ -0,0 +1,102 @@
+package router
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limiters counts the requests and cache misses of each client IP and API key, so the same limits apply across the
+// middleware of the router, and to work done later on behalf of a client (e.g. a job).
+type Limiters struct {
+	// Rate-limits for each client IP and API key.
+	ips  *clientLimiters
+	keys *clientLimiters
+
+	// The limits of each API key itself, by the hash of its secret.
+	mu     sync.Mutex
+	usages map[string]*keyUsage
+}
+
+// NewLimiters creates limiters with the given rate-limits for each client IP and API key, in addition to the limits of
+// each key itself.
+func NewLimiters(limits RateLimits) *Limiters {
+	return &Limiters{
+		ips:    newClientLimiters(limits.IPHitsPerMinute, limits.IPMissesPerMinute),
+		keys:   newClientLimiters(limits.KeyHitsPerMinute, limits.KeyMissesPerMinute),
+		usages: make(map[string]*keyUsage),
+	}
+}
+
+// Client identifies the client of a request, so work on its behalf outside the request can be limited in the same way.
+type Client struct {
+	IP string
+
+	// The hash of the API key presented, and the key (without its secret), if authenticated.
+	KeyID string
+	Key   *APIKey
+}
+
+// clientKey is the API key that authenticated a request, with the hash of its secret.
+type clientKey struct {
+	id  string
+	key APIKey
+}
+
+// RequestClient identifies the client of a request, after any authentication.
+func RequestClient(c *gin.Context) Client {
+	client := Client{IP: c.ClientIP()}
+	if value, ok := c.Get(clientKeyContextKey); ok {
+		authenticated := value.(clientKey)
+		key := authenticated.key
+		key.Key = ""
+		client.KeyID = authenticated.id
+		client.Key = &key
+	}
+	return client
+}
+
+// WithClient applies the limits on the cache misses of a client to ctx, so ChargeMiss counts against them as for the
+// client's requests.
+//
+// The API key's own limits are those in client, unless the key has since authenticated a request, whose limits are
+// more recent.
+func (l *Limiters) WithClient(ctx context.Context, client Client) context.Context {
+	now := time.Now()
+	if client.Key != nil {
+		ctx = appendMissLimiter(ctx, l.keyUsage(client.KeyID, *client.Key, false))
+		ctx = appendMissLimiter(ctx, &clientMiss{client: l.keys.get(keyClientName(client.Key.Name), now)})
+	}
+	if client.IP != "" {
+		ctx = appendMissLimiter(ctx, &clientMiss{client: l.ips.get(ipClientName(client.IP), now)})
+	}
+	return ctx
+}
+
+// keyUsage retrieves (or creates) the usage of an API key, applying the limits of key if update is true or the usage
+// is new.
+func (l *Limiters) keyUsage(id string, key APIKey, update bool) *keyUsage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	usage, ok := l.usages[id]
+	if !ok {
+		usage = &keyUsage{}
+		l.usages[id] = usage
+	}
+	if update || !ok {
+		usage.configure(key)
+	}
+	return usage
+}
+
+// ipClientName names a client IP in the messages of its rate-limits.
+func ipClientName(ip string) string {
+	return "IP " + ip
+}
+
+// keyClientName names an API key in the messages of its rate-limits.
+func keyClientName(name string) string {
+	return "API key " + name
+}
@@ -0,0 +1,129 @@
+package router
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// unixPrefix marks an address as the path of a Unix domain socket, rather than a TCP host:port.
+const unixPrefix = "unix:"
+
+// newTLSConfig creates a configuration to serve TLS, reloading the certificate when the files change.
+//
+// If certFile and keyFile are both empty, nil is returned, as TLS is not used.
+func newTLSConfig(certFile string, keyFile string) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" {
+		return nil, nil
+	} else if certFile == "" || keyFile == "" {
+		return nil, errors.New("both a TLS certificate and key file are required, or neither")
+	}
+
+	reloader, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.getCertificate,
+	}, nil
+}
+
+// listen creates a listener for addr, which is either a TCP host:port or unix: followed by the path of a socket.
+func listen(addr string) (net.Listener, error) {
+	path, isUnix := strings.CutPrefix(addr, unixPrefix)
+	if !isUnix {
+		return net.Listen("tcp", addr)
+	}
+
+	// A socket left behind by a previous process (e.g. after a crash) would otherwise prevent listening
+	if info, err := os.Stat(path); err == nil && info.Mode().Type() == fs.ModeSocket {
+		if conn, err := net.Dial("unix", path); err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("socket %s is already in use (is another instance of the service running?)", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("cannot remove existing socket %s: %w", path, err)
+		}
+	}
+
+	// The socket file is removed again when the listener is closed
+	return net.Listen("unix", path)
+}
+
+// certReloader provides a TLS certificate, reloading it whenever the certificate or key file is modified.
+//
+// This allows certificates to be rotated (e.g. by cert-manager) without restarting the server.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modTimes [2]time.Time
+}
+
+// newCertReloader loads the certificate initially, failing if it cannot be loaded.
+func newCertReloader(certFile string, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	modTimes, err := r.readModTimes()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(modTimes); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// getCertificate is called for each TLS handshake, first reloading the certificate if its files have been modified.
+//
+// If reloading fails (e.g. while the files are only partially written), the previous certificate continues to be used.
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modTimes, err := r.readModTimes()
+	if err == nil && modTimes != r.modTimes {
+		err = r.load(modTimes)
+		if err == nil {
+			log.Info().Str("certificate", r.certFile).Msg("Reloaded the TLS certificate")
+		}
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to reload the TLS certificate, so continuing with the previous certificate")
+	}
+	return r.cert, nil
+}
+
+// load reads the certificate and key, remembering modTimes to detect later changes.
+func (r *certReloader) load(modTimes [2]time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load the TLS certificate %s and key %s: %w", r.certFile, r.keyFile, err)
+	}
+	r.cert = &cert
+	r.modTimes = modTimes
+	return nil
+}
+
+// readModTimes reads the modification times of the certificate and key files (following any symlinks).
+func (r *certReloader) readModTimes() ([2]time.Time, error) {
+	var modTimes [2]time.Time
+	for i, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return modTimes, fmt.Errorf("cannot read TLS file: %w", err)
+		}
+		modTimes[i] = info.ModTime()
+	}
+	return modTimes, nil
+}
@@ -0,0 +1,40 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owenfeehan/geocoding-nominatim-cache/fetcher"
+)
+
+// PriorityHeader is the header in which clients may request the bulk priority for their cache misses.
+const PriorityHeader = "X-Priority"
+
+// Prioritize lowers the priority of a request's cache misses to bulk, if requested in the X-Priority header, so they
+// wait behind interactive requests to Nominatim.
+//
+// A client cannot raise its priority this way, e.g. above the class of its API key.
+func Prioritize() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.GetHeader(PriorityHeader)
+		if name == "" {
+			c.Next()
+			return
+		}
+
+		priority, err := fetcher.ParsePriority(name)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if priority == fetcher.PriorityBulk {
+			withBulkPriority(c)
+		}
+		c.Next()
+	}
+}
+
+// withBulkPriority lowers the priority of a request's cache misses to bulk.
+func withBulkPriority(c *gin.Context) {
+	c.Request = c.Request.WithContext(fetcher.WithPriority(c.Request.Context(), fetcher.PriorityBulk))
+}
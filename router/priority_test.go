@@ -0,0 +1,70 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owenfeehan/geocoding-nominatim-cache/fetcher"
+)
+
+func TestPrioritize(t *testing.T) {
+	keys := loadTestKeys(t, `[{"key": "s3cret", "name": "app"}, {"key": "imp0rt", "name": "importer", "class": "bulk"}]`)
+	engine := createPriorityEngine(Authenticate(keys), Prioritize())
+
+	for _, test := range []struct {
+		key, priority string
+		want          string
+	}{
+		{"s3cret", "", "interactive"},
+		{"s3cret", "bulk", "bulk"},
+		{"imp0rt", "", "bulk"},
+		// A bulk key cannot raise its priority
+		{"imp0rt", "interactive", "bulk"},
+	} {
+		recorder := servePriorityRequest(engine, test.key, test.priority)
+		if got := recorder.Body.String(); recorder.Code != http.StatusOK || got != test.want {
+			t.Errorf("expected %s for key %s with priority %q, got %d %s", test.want, test.key, test.priority, recorder.Code, got)
+		}
+	}
+
+	if recorder := servePriorityRequest(engine, "s3cret", "urgent"); recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unknown priority, got %d", recorder.Code)
+	}
+}
+
+func TestLoadKeyFileInvalidClass(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(path, []byte(`[{"key": "s3cret", "name": "app", "class": "urgent"}]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKeyFile(path); err == nil {
+		t.Error("expected an error for an unknown class")
+	}
+}
+
+// createPriorityEngine creates an engine whose handler responds with the priority of the request.
+func createPriorityEngine(middleware ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(middleware...)
+	engine.GET("/test", func(c *gin.Context) {
+		c.String(http.StatusOK, fetcher.PriorityFromContext(c.Request.Context()).String())
+	})
+	return engine
+}
+
+// servePriorityRequest serves a request with an API key, and a priority header if not empty.
+func servePriorityRequest(engine *gin.Engine, key string, priority string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set(APIKeyHeader, key)
+	if priority != "" {
+		req.Header.Set(PriorityHeader, priority)
+	}
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, req)
+	return recorder
+}
@@ -0,0 +1,204 @@
+package router
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// idleClientTimeout is how long a client may be idle, before its limiters are discarded.
+//
+// This is longer than a minute, after which any limiter would anyway have refilled.
+const idleClientTimeout = 10 * time.Minute
+
+// RateLimits configures the inbound rate-limits applied to each client, in requests per minute. Zero is unlimited.
+//
+// Requests served from the cache (hits) and those requiring a request to Nominatim (misses) have separate budgets,
+// so a client's cached requests are not held up by its uncached ones, and no client can monopolise the queue to Nominatim.
+type RateLimits struct {
+	// Limits for each client IP (as determined using the trusted proxies).
+	IPHitsPerMinute   int
+	IPMissesPerMinute int
+
+	// Limits for each API key, if authenticated.
+	KeyHitsPerMinute   int
+	KeyMissesPerMinute int
+}
+
+// RateLimit limits the requests of each client IP, and of each API key (if Authenticate precedes it).
+//
+// A request is refused if the client has no hits remaining. If it then requires a request to Nominatim (see ChargeMiss),
+// it is counted as a miss, otherwise as a hit once handled. Requests exceeding a limit are refused with 429 Too Many
+// Requests and a Retry-After header.
+func RateLimit(limits RateLimits) gin.HandlerFunc {
+	return NewLimiters(limits).RateLimit()
+}
+
+// RateLimit limits the requests of each client IP, and of each API key, as counted in l.
+func (l *Limiters) RateLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now := time.Now()
+		clients := []*clientLimiter{l.ips.get(ipClientName(c.ClientIP()), now)}
+		if name := c.GetString(KeyNameContextKey); name != "" {
+			clients = append(clients, l.keys.get(keyClientName(name), now))
+		}
+
+		for _, client := range clients {
+			if err := client.checkHit(now); err != nil {
+				AbortWithLimitError(c, err)
+				return
+			}
+		}
+
+		misses := make([]*clientMiss, len(clients))
+		for i, client := range clients {
+			misses[i] = &clientMiss{client: client}
+			withMissLimiter(c, misses[i])
+		}
+
+		c.Next()
+
+		for _, miss := range misses {
+			if !miss.charged.Load() {
+				miss.client.chargeHit(time.Now())
+			}
+		}
+	}
+}
+
+// clientLimiters holds the limiters for each client of one type (e.g. IP), discarding those of idle clients.
+type clientLimiters struct {
+	hitsPerMinute   int
+	missesPerMinute int
+
+	mu        sync.Mutex
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+}
+
+func newClientLimiters(hitsPerMinute int, missesPerMinute int) *clientLimiters {
+	return &clientLimiters{
+		hitsPerMinute:   hitsPerMinute,
+		missesPerMinute: missesPerMinute,
+		clients:         make(map[string]*clientLimiter),
+		lastSweep:       time.Now(),
+	}
+}
+
+// get retrieves (or creates) the limiters for a client.
+func (l *clientLimiters) get(name string, now time.Time) *clientLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > idleClientTimeout {
+		for key, client := range l.clients {
+			if now.Sub(client.lastSeen) > idleClientTimeout {
+				delete(l.clients, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	client, ok := l.clients[name]
+	if !ok {
+		client = &clientLimiter{
+			name:   name,
+			hits:   newPerMinuteLimiter(l.hitsPerMinute),
+			misses: newPerMinuteLimiter(l.missesPerMinute),
+		}
+		l.clients[name] = client
+	}
+	client.lastSeen = now
+	return client
+}
+
+// clientLimiter limits the hits and misses of a single client.
+type clientLimiter struct {
+	name string
+
+	// Limits the rate of hits or misses, or nil if unlimited.
+	hits   *rate.Limiter
+	misses *rate.Limiter
+
+	// When the client last made a request (guarded by the mutex of clientLimiters).
+	lastSeen time.Time
+}
+
+// checkHit returns a LimitError if the client has no hits remaining, without counting a hit.
+//
+// Hits are only counted once a request has been handled, as it is not known beforehand whether a request is a hit.
+func (l *clientLimiter) checkHit(now time.Time) *LimitError {
+	if l.hits == nil {
+		return nil
+	}
+	tokens := l.hits.TokensAt(now)
+	if tokens >= 1 {
+		return nil
+	}
+	return &LimitError{
+		Message:    fmt.Sprintf("the rate-limit of %d requests per minute for %s is exceeded", l.hits.Burst(), l.name),
+		RetryAfter: time.Duration((1 - tokens) / float64(l.hits.Limit()) * float64(time.Second)),
+	}
+}
+
+// chargeHit counts a hit, even if concurrent requests have meanwhile exhausted the hits (delaying later requests instead).
+func (l *clientLimiter) chargeHit(now time.Time) {
+	if l.hits != nil {
+		l.hits.ReserveN(now, 1)
+	}
+}
+
+// clientMiss counts a request as a miss, rather than a hit, for a client.
+type clientMiss struct {
+	client *clientLimiter
+
+	// Whether the request was counted as a miss.
+	charged atomic.Bool
+}
+
+func (m *clientMiss) reserveMiss(now time.Time) (func(), *LimitError) {
+	misses := m.client.misses
+	reservation, err := reserve(misses, now, func() string {
+		return fmt.Sprintf("the rate-limit of %d uncached requests per minute for %s is exceeded", misses.Burst(), m.client.name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.charged.Store(true)
+	return func() {
+		m.charged.Store(false)
+		cancelReservation(reservation, now)
+	}, nil
+}
+
+// newPerMinuteLimiter creates a limiter allowing a burst of up to perMinute requests, or nil if perMinute is zero (unlimited).
+func newPerMinuteLimiter(perMinute int) *rate.Limiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(float64(perMinute)/60), perMinute)
+}
+
+// reserve takes a token from a limiter (which may be nil if unlimited), or returns a LimitError with the given message if none remain.
+func reserve(limiter *rate.Limiter, now time.Time, message func() string) (*rate.Reservation, *LimitError) {
+	if limiter == nil {
+		return nil, nil
+	}
+	reservation := limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return nil, &LimitError{Message: message(), RetryAfter: delay}
+	}
+	return reservation, nil
+}
+
+// cancelReservation returns a token to its limiter, if there was a reservation.
+func cancelReservation(reservation *rate.Reservation, now time.Time) {
+	if reservation != nil {
+		reservation.CancelAt(now)
+	}
+}
@@ -0,0 +1,78 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimitSeparatesHitsAndMisses(t *testing.T) {
+	engine := createRateLimitEngine(RateLimit(RateLimits{IPHitsPerMinute: 2, IPMissesPerMinute: 1}))
+
+	// A miss does not use the budget for hits
+	assertStatus(t, engine, "/miss", "10.0.0.1", http.StatusOK)
+	assertStatus(t, engine, "/hit", "10.0.0.1", http.StatusOK)
+	assertStatus(t, engine, "/hit", "10.0.0.1", http.StatusOK)
+
+	recorder := assertStatus(t, engine, "/hit", "10.0.0.1", http.StatusTooManyRequests)
+	if retry, err := strconv.Atoi(recorder.Header().Get("Retry-After")); err != nil || retry < 1 || retry > 60 {
+		t.Errorf("expected to retry within a minute, got %q", recorder.Header().Get("Retry-After"))
+	}
+
+	// Other clients have their own budgets
+	assertStatus(t, engine, "/miss", "10.0.0.2", http.StatusOK)
+	assertStatus(t, engine, "/miss", "10.0.0.2", http.StatusTooManyRequests)
+	assertStatus(t, engine, "/hit", "10.0.0.2", http.StatusOK)
+}
+
+func TestRateLimitPerKey(t *testing.T) {
+	keys := loadTestKeys(t, `[{"key": "s3cret", "name": "test"}]`)
+	engine := createRateLimitEngine(Authenticate(keys), RateLimit(RateLimits{KeyMissesPerMinute: 1}))
+
+	// The budget of the key is shared across IPs
+	assertStatus(t, engine, "/miss", "10.0.0.1", http.StatusOK)
+	assertStatus(t, engine, "/miss", "10.0.0.2", http.StatusTooManyRequests)
+}
+
+func TestClientLimitersDiscardIdle(t *testing.T) {
+	limiters := newClientLimiters(1, 1)
+	start := time.Now()
+	limiters.get("first", start)
+
+	later := start.Add(2 * idleClientTimeout)
+	limiters.get("second", later)
+	if _, ok := limiters.clients["first"]; ok {
+		t.Error("expected the idle client to be discarded")
+	}
+	if _, ok := limiters.clients["second"]; !ok {
+		t.Error("expected the active client to be retained")
+	}
+}
+
+// Creates an engine with a /hit endpoint, and a /miss endpoint that charges a miss, after the given middleware.
+func createRateLimitEngine(middleware ...gin.HandlerFunc) *gin.Engine {
+	engine := createTestEngine(middleware...)
+	engine.GET("/hit", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	engine.GET("/miss", chargeMissHandler)
+	return engine
+}
+
+// Asserts the status of a request (authenticated with the test key) from a client IP.
+func assertStatus(t *testing.T, engine *gin.Engine, path string, ip string, want int) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = ip + ":1234"
+	req.Header.Set(APIKeyHeader, "s3cret")
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, req)
+	if recorder.Code != want {
+		t.Errorf("expected status %d for %s from %s, got %d", want, path, ip, recorder.Code)
+	}
+	return recorder
+}
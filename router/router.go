@@ -2,7 +2,10 @@
 package router
 
 import (
+	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -12,33 +15,155 @@ import (
 	_ "github.com/owenfeehan/geocoding-nominatim-cache/docs" // docs is generated by swag init
 )
 
-// CreateRunRouter creates, configures, and runs the Gin router
+// Routes contains the handlers for the endpoints served by the router.
 //
-// The forwardGeocde is a gin.HandlerFunc that handles the /locations/:place endpoint (for forward geocoding).
+// Optional handlers may be nil, in which case the corresponding endpoint is not served.
+type Routes struct {
+	// Middleware applied to every route, before its handler (optional).
+	Middleware []gin.HandlerFunc
+
+	// Authenticates requests to the geocoding endpoints, and limits their cache misses (optional).
+	Auth gin.HandlerFunc
+
+	// Limits the rate of requests to the geocoding endpoints from each client, after any authentication (optional).
+	RateLimit gin.HandlerFunc
+
+	// Handles the /locations/:place endpoint (for forward geocoding).
+	ForwardGeocode gin.HandlerFunc
+
+	// Handles the /locations/batch endpoint (for forward geocoding of many queries).
+	BatchGeocode gin.HandlerFunc
+
+	// Handles the /locations/batch.csv endpoint (for forward geocoding of each row of a CSV).
+	BatchGeocodeCSV gin.HandlerFunc
+
+	// Handles the /locations/osm/:ids endpoint (for looking up locations by OSM ID).
+	LookupOSM gin.HandlerFunc
+
+	// Handle the /jobs endpoints, which submit jobs and report their progress and results (optional).
+	SubmitJob  gin.HandlerFunc
+	GetJob     gin.HandlerFunc
+	JobResults gin.HandlerFunc
+	JobEvents  gin.HandlerFunc
+
+	// Handle the Nominatim-compatible /search, /reverse and /lookup endpoints.
+	Search  gin.HandlerFunc
+	Reverse gin.HandlerFunc
+	Lookup  gin.HandlerFunc
+
+	// Handles the /healthz endpoint, which reports whether the process is alive.
+	Health gin.HandlerFunc
+
+	// Handles the /readyz endpoint, which reports whether the service is ready to handle requests.
+	Ready gin.HandlerFunc
+
+	// Handles the /status endpoint, which reports the status of the service (like Nominatim).
+	Status gin.HandlerFunc
+
+	// Handles the /metrics endpoint, which exposes metrics for Prometheus (optional).
+	Metrics gin.HandlerFunc
+
+	// Handles the /admin/backup endpoint, which writes a backup of the store (optional). As it requires an admin API key,
+	// it is only served with Auth.
+	Backup gin.HandlerFunc
+}
+
+// ServerOptions configures how the router is served.
+type ServerOptions struct {
+	// The address on which the server will listen: a TCP host:port (e.g., "localhost:8080"), or unix: followed by the path of a socket.
+	Address string
+
+	// A comma-separated list of trusted proxy IPs or CIDRs, used for configuring the Gin router.
+	TrustedProxies string
+
+	// PEM files of the certificate and key, to serve with TLS. If empty, TLS is not used.
+	//
+	// The certificate is reloaded whenever either file is modified.
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// Configures Cross-Origin Resource Sharing for browser clients. If empty, CORS is disabled.
+	CORS CORSOptions
+
+	// How long in-flight requests may take to complete after shutdown begins, before they too are cancelled.
+	DrainTimeout time.Duration
+}
+
+// CreateRunRouter creates, configures, and runs the Gin router until ctx is cancelled (e.g. by a signal).
+//
+// routes contains the handlers for the endpoints.
 //
-// addr is the address on which the server will listen (e.g., "localhost:8080").
-// proxyList is a comma-separated list of trusted proxy IPs or CIDRs, used for configuring the Gin router.
-func CreateRunRouter(addr string, proxyList string, forwardGeocode gin.HandlerFunc) error {
-	router, err := createRouter(proxyList)
+// It returns once the server has shut down and all handlers have returned, so it is then safe to close the store etc.
+func CreateRunRouter(ctx context.Context, opts ServerOptions, routes Routes) error {
+	router, err := createRouter(opts.TrustedProxies, opts.CORS)
 
 	if err != nil {
 		return fmt.Errorf("failed to create router: %w", err)
 	}
 
-	configureRouter(router, forwardGeocode)
+	var inFlight sync.WaitGroup
+	router.Use(trackInFlight(&inFlight))
 
-	if err := router.Run(addr); err != nil {
+	configureRouter(router, routes)
+
+	tlsConfig, err := newTLSConfig(opts.TLSCertFile, opts.TLSKeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to configure TLS: %w", err)
+	}
+
+	listener, err := listen(opts.Address)
+	if err != nil {
 		return fmt.Errorf("failed to start server: %w", err)
 	}
 
-	return nil
+	return serve(ctx, listener, tlsConfig, router.Handler(), &inFlight, opts.DrainTimeout)
 }
 
 // configureRouter attaches all routes to the router using the app instance
-func configureRouter(router *gin.Engine, forwardGeocode gin.HandlerFunc) {
+func configureRouter(router *gin.Engine, routes Routes) {
+
+	router.Use(routes.Middleware...)
 
 	// Swagger endpoint
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	router.GET("/locations/:place", forwardGeocode)
+	// Geocoding endpoints, which may require authentication
+	geocoding := router.Group("/")
+	if routes.Auth != nil {
+		geocoding.Use(routes.Auth)
+	}
+	if routes.RateLimit != nil {
+		geocoding.Use(routes.RateLimit)
+	}
+	geocoding.Use(Prioritize())
+	geocoding.GET("/locations/:place", routes.ForwardGeocode)
+	geocoding.GET("/locations/osm/:ids", routes.LookupOSM)
+	geocoding.POST("/locations/batch", routes.BatchGeocode)
+	geocoding.POST("/locations/batch.csv", routes.BatchGeocodeCSV)
+	if routes.SubmitJob != nil {
+		geocoding.POST("/jobs", routes.SubmitJob)
+		geocoding.GET("/jobs/:id", routes.GetJob)
+		geocoding.GET("/jobs/:id/results", routes.JobResults)
+		geocoding.GET("/jobs/:id/events", routes.JobEvents)
+	}
+
+	// Nominatim-compatible endpoints, alongside /status
+	geocoding.GET("/search", routes.Search)
+	geocoding.GET("/reverse", routes.Reverse)
+	geocoding.GET("/lookup", routes.Lookup)
+
+	// Health endpoints e.g. for Kubernetes probes
+	router.GET("/healthz", routes.Health)
+	router.GET("/readyz", routes.Ready)
+	router.GET("/status", routes.Status)
+
+	if routes.Metrics != nil {
+		router.GET("/metrics", routes.Metrics)
+	}
+
+	// Admin endpoints require an admin API key
+	if routes.Backup != nil && routes.Auth != nil {
+		admin := router.Group("/admin", routes.Auth, RequireAdmin())
+		admin.POST("/backup", routes.Backup)
+	}
 }
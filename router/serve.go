@@ -0,0 +1,95 @@
+package router
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// shutdownKey is the key in the context of a request of the channel closed when the server begins to shut down.
+type shutdownKey struct{}
+
+// WithShutdown adds to ctx a channel that is closed when the server begins to shut down.
+func WithShutdown(ctx context.Context, shutdown <-chan struct{}) context.Context {
+	return context.WithValue(ctx, shutdownKey{}, shutdown)
+}
+
+// ShuttingDown returns a channel that is closed when the server serving the request with ctx begins to shut down, so
+// requests that would otherwise never complete (e.g. streams of events) can end without waiting for the drain timeout.
+//
+// If ctx is not of a request to the server, the channel is never closed.
+func ShuttingDown(ctx context.Context) <-chan struct{} {
+	shutdown, _ := ctx.Value(shutdownKey{}).(<-chan struct{})
+	return shutdown
+}
+
+// serve accepts connections from listener to handler until ctx is cancelled, and then shuts down gracefully.
+//
+// If tlsConfig is non-nil, connections are served with TLS (and HTTP/2 if negotiated).
+//
+// In-flight requests are given drainTimeout to complete. Afterwards, the contexts of any remaining requests are cancelled
+// (e.g. ending any waits to be throttled), their connections closed, and serve returns only once their handlers have returned.
+// Handlers that never complete by themselves should end once ShuttingDown is closed.
+func serve(ctx context.Context, listener net.Listener, tlsConfig *tls.Config, handler http.Handler, inFlight *sync.WaitGroup, drainTimeout time.Duration) error {
+	// Requests are not cancelled by ctx, so they can be drained, but instead when the drain timeout expires.
+	requestCtx, cancelRequests := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelRequests()
+	shutdown := make(chan struct{})
+	requestCtx = WithShutdown(requestCtx, shutdown)
+
+	server := &http.Server{
+		Handler:     handler,
+		BaseContext: func(net.Listener) context.Context { return requestCtx },
+		TLSConfig:   tlsConfig,
+	}
+	server.RegisterOnShutdown(func() { close(shutdown) })
+
+	errs := make(chan error, 1)
+	go func() {
+		if tlsConfig != nil {
+			// The certificate is provided by the configuration, rather than files
+			errs <- server.ServeTLS(listener, "", "")
+		} else {
+			errs <- server.Serve(listener)
+		}
+	}()
+	log.Info().Str("address", listener.Addr().String()).Msg("Server is running")
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	log.Info().Dur("drain timeout", drainTimeout).Msg("Shutting down the server, after in-flight requests complete")
+	drainCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	err := server.Shutdown(drainCtx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Warn().Msg("In-flight requests did not complete before the drain timeout, so are being cancelled")
+		cancelRequests()
+		err = server.Close()
+	}
+	inFlight.Wait()
+	return err
+}
+
+// trackInFlight adds each request to inFlight until its handler returns.
+//
+// Unlike http.Server.Shutdown, this also waits for requests whose connections have already been closed.
+func trackInFlight(inFlight *sync.WaitGroup) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inFlight.Add(1)
+		defer inFlight.Done()
+		c.Next()
+	}
+}
@@ -0,0 +1,50 @@
+package router
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestServeEndsStreamsOnShutdown(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A stream that only ends once the server shuts down
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		close(started)
+		<-ShuttingDown(r.Context())
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var inFlight sync.WaitGroup
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, listener, nil, handler, &inFlight, time.Minute)
+	}()
+
+	response, err := http.Get("http://" + listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	<-started
+
+	cancel()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the server to shut down without waiting for the drain timeout")
+	}
+}
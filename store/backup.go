@@ -0,0 +1,184 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// Prefix, suffix and timestamp-format of the names of backup files written to a BackupDirectory.
+const (
+	backupPrefix     = "locations-"
+	backupSuffix     = ".bak"
+	backupTimeLayout = "20060102T150405.000000000Z"
+)
+
+// ErrBackupUnsupported is returned when backing up a store that does not implement Backuper.
+var ErrBackupUnsupported = errors.New("backups are only supported by the BadgerDB store")
+
+// Backuper is implemented by stores that can write a backup of their entries while remaining online.
+type Backuper interface {
+	// Writes a full backup of the store to w.
+	Backup(w io.Writer) error
+}
+
+// BackupDirectory writes backups of a store into a directory, retaining only the most recent.
+//
+// It is safe for concurrent use, with backups occurring one at a time.
+type BackupDirectory struct {
+	source Backuper
+	dir    string
+	retain int
+
+	mu sync.Mutex
+}
+
+// NewBackupDirectory creates a BackupDirectory for the given store, creating dir if it does not already exist.
+//
+// retain is the number of backups to keep, with older backups deleted after each new backup. If zero or less, all backups are kept.
+//
+// ErrBackupUnsupported is returned if the store does not support backups.
+func NewBackupDirectory(locStore LocationStore, dir string, retain int) (*BackupDirectory, error) {
+	source, ok := locStore.(Backuper)
+	if !ok {
+		return nil, ErrBackupUnsupported
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	return &BackupDirectory{source: source, dir: dir, retain: retain}, nil
+}
+
+// Backup writes a new backup into the directory, and deletes any backups beyond those to retain.
+//
+// It returns the path of the new backup file.
+func (b *BackupDirectory) Backup() (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	path := filepath.Join(b.dir, backupPrefix+time.Now().UTC().Format(backupTimeLayout)+backupSuffix)
+	if err := b.writeBackup(path); err != nil {
+		return "", err
+	}
+
+	log.Info().Str("path", path).Msg("Wrote backup of the location-store")
+
+	if err := b.prune(); err != nil {
+		return path, fmt.Errorf("failed to delete old backups: %w", err)
+	}
+	return path, nil
+}
+
+// RunSchedule writes a backup after every interval, until the context is cancelled.
+//
+// Failed backups are logged, and do not stop the schedule.
+func (b *BackupDirectory) RunSchedule(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := b.Backup(); err != nil {
+				log.Error().Err(err).Msg("Scheduled backup failed")
+			}
+		}
+	}
+}
+
+// writeBackup writes to a temporary file that is renamed when complete, so partial backups are never retained.
+func (b *BackupDirectory) writeBackup(path string) error {
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+
+	err = b.source.Backup(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// prune deletes the oldest backups in the directory, so that only the most recent are retained.
+func (b *BackupDirectory) prune() error {
+	if b.retain <= 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return err
+	}
+
+	// The timestamp format ensures lexical order is also chronological order
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, backupSuffix) {
+			backups = append(backups, name)
+		}
+	}
+	sort.Strings(backups)
+
+	for len(backups) > b.retain {
+		if err := os.Remove(filepath.Join(b.dir, backups[0])); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// RestoreBadgerBackup loads a backup (as written by a BackupDirectory) into a new BadgerDB in dir.
+//
+// dir must either not exist, or be an empty directory, to avoid mixing the backup with existing entries.
+func RestoreBadgerBackup(backupPath string, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("cannot read store directory: %w", err)
+	} else if len(entries) > 0 {
+		return fmt.Errorf("store directory %s is not empty, restore requires a fresh directory", dir)
+	}
+
+	file, err := os.Open(backupPath)
+	if err != nil {
+		return fmt.Errorf("cannot open backup: %w", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("Error closing backup file: %v", err)
+		}
+	}()
+
+	db, err := badger.Open(badger.DefaultOptions(dir).WithLoggingLevel(badger.WARNING))
+	if err != nil {
+		return err
+	}
+
+	err = db.Load(file, 256)
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	location "github.com/owenfeehan/geocoding-nominatim-cache/location"
+)
+
+func TestBackupRetainsMostRecent(t *testing.T) {
+	store := createBadgerStore(t, t.TempDir())
+
+	backupDir := filepath.Join(t.TempDir(), "backups")
+	backups, err := NewBackupDirectory(store, backupDir, 2)
+	if err != nil {
+		t.Fatalf("Failed to create backup directory: %v", err)
+	}
+
+	var paths []string
+	for range 3 {
+		path, err := backups.Backup()
+		if err != nil {
+			t.Fatalf("Backup failed: %v", err)
+		}
+		paths = append(paths, path)
+	}
+
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		t.Fatalf("Cannot read backup directory: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("Expected 2 backups to be retained, got %d", len(entries))
+	}
+	if _, err := os.Stat(paths[0]); !os.IsNotExist(err) {
+		t.Errorf("Expected the oldest backup %s to be deleted", paths[0])
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	store := createBadgerStore(t, t.TempDir())
+	locs := []location.Location{{DisplayName: "Brussels, Belgium", Latitude: "50.8503", Longitude: "4.3517"}}
+	if err := store.Set(context.Background(), store.BuildKey("Brussels"), locs); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	backups, err := NewBackupDirectory(store, t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("Failed to create backup directory: %v", err)
+	}
+	path, err := backups.Backup()
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	restoreDir := filepath.Join(t.TempDir(), "restored")
+	if err := RestoreBadgerBackup(path, restoreDir); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	// Restoring again into the same directory is refused, as it is no longer empty
+	if err := RestoreBadgerBackup(path, restoreDir); err == nil {
+		t.Error("Expected restoring into a non-empty directory to fail")
+	}
+
+	restored := createBadgerStore(t, restoreDir)
+	got, err := restored.Get(context.Background(), restored.BuildKey("Brussels"))
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, locs) {
+		t.Errorf("Expected %v, got %v", locs, got)
+	}
+}
+
+func TestBackupUnsupported(t *testing.T) {
+	if _, err := NewBackupDirectory(NewMemoryStore(), t.TempDir(), 1); err != ErrBackupUnsupported {
+		t.Errorf("Expected ErrBackupUnsupported, got %v", err)
+	}
+}
+
+// createBadgerStore opens a badger store in the given directory, closed when the test ends.
+func createBadgerStore(t *testing.T, path string) LocationStore {
+	store, err := NewBadgerStore(&path)
+	if err != nil {
+		t.Fatalf("Failed to create Badger store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
@@ -1,13 +1,21 @@
 package store
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/rs/zerolog/log"
 
 	badger "github.com/dgraph-io/badger/v4"
+	"github.com/dgraph-io/badger/v4/options"
 	"github.com/getlantern/appdir"
 	location "github.com/owenfeehan/geocoding-nominatim-cache/location"
 )
@@ -15,8 +23,50 @@ import (
 // badgerStore implements LocationStore using BadgerDB as the backend.
 type badgerStore struct {
 	db *badger.DB
+
+	// Runs garbage collection and size checks in the background, or nil if not required
+	maintenance *badgerMaintenance
+}
+
+// BadgerOptions configures a BadgerDB store.
+//
+// The zero value opens the default data directory, without any background maintenance.
+type BadgerOptions struct {
+	// The folder-path for the badger DB. If empty, a default data directory under the user's app data directory is used.
+	Path string
+
+	// Keeps all data in memory, without any folder-path. Garbage collection and maximum sizes are then ignored.
+	InMemory bool
+
+	// The compression applied to data blocks: "none", "snappy" or "zstd". If empty, BadgerDB's default (snappy) is used.
+	Compression string
+
+	// Whether to sync all writes to disk immediately. This is slower, but no writes are lost if the machine crashes.
+	SyncWrites bool
+
+	// The size in bytes of the block cache. If zero, BadgerDB's default is used.
+	BlockCacheSize int64
+
+	// The size in bytes of the index cache. If zero, indices are kept in memory without a cache.
+	IndexCacheSize int64
+
+	// How often to run value-log garbage collection. If zero, garbage collection never occurs.
+	GCInterval time.Duration
+
+	// A value-log file is rewritten by garbage collection, if at least this fraction of it can be discarded.
+	//
+	// It must be in the range (0, 1), with BadgerDB recommending 0.5.
+	GCDiscardRatio float64
+
+	// The maximum disk space in bytes used by the store, after which new entries are refused with ErrStoreFull.
+	//
+	// If zero, the size is unlimited.
+	MaxSize int64
 }
 
+// ErrLocked is returned when a BadgerDB directory is already open in another process, e.g. a running service.
+var ErrLocked = errors.New("locked by another process")
+
 // NewBadgerStore opens (or creates) a BadgerDB at the given path and returns a LocationStore.
 //
 // This provides a convenient persistent data-store on the file-system.
@@ -26,24 +76,109 @@ type badgerStore struct {
 // If the path is nil, it will create a default data directory under the user's app data directory, otherwise it will use the
 // provided folder-path for the badger DB.
 func NewBadgerStore(path *string) (LocationStore, error) {
+	var opts BadgerOptions
+	if path != nil {
+		opts.Path = *path
+	}
+	return NewBadgerStoreWithOptions(opts)
+}
+
+// NewBadgerStoreWithOptions opens (or creates) a BadgerDB as configured by opts and returns a LocationStore.
+//
+// If garbage collection or a maximum size is configured, a background goroutine maintains the store until it is closed.
+func NewBadgerStoreWithOptions(opts BadgerOptions) (LocationStore, error) {
 
-	// Calculate a path, if not already provided
-	if path == nil {
+	if opts.InMemory {
+		log.Info().Msg("Opening in-memory BadgerDB store")
+		opts.Path = ""
+		opts.GCInterval = 0
+		opts.MaxSize = 0
+	} else if opts.Path == "" {
+		// Calculate a path, if not already provided
 		pathDataDir, err := pathDataDirectory()
 		if err != nil {
 			return nil, err
 		}
 
-		path = &pathDataDir
+		opts.Path = pathDataDir
+	} else if err := validateDataDirectory(opts.Path); err != nil {
+		return nil, err
 	}
 
-	log.Info().Str("BadgerDB store directory", *path).Msg("Opening BadgerDB store")
+	if opts.GCInterval > 0 && (opts.GCDiscardRatio <= 0 || opts.GCDiscardRatio >= 1) {
+		return nil, fmt.Errorf("the garbage collection discard ratio must be between 0 and 1 (exclusive), but is %v", opts.GCDiscardRatio)
+	}
 
-	db, err := badger.Open(badger.DefaultOptions(*path).WithLoggingLevel(badger.WARNING))
+	badgerOpts, err := toBadgerOptions(opts)
 	if err != nil {
 		return nil, err
 	}
-	return &badgerStore{db: db}, nil
+
+	if !opts.InMemory {
+		log.Info().Str("BadgerDB store directory", opts.Path).Msg("Opening BadgerDB store")
+	}
+
+	db, err := badger.Open(badgerOpts)
+	if err != nil && strings.Contains(err.Error(), "Cannot acquire directory lock") {
+		return nil, fmt.Errorf("BadgerDB directory %s is %w (is another instance of the service running?): %w", opts.Path, ErrLocked, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open BadgerDB in %s: %w", opts.Path, err)
+	}
+
+	store := &badgerStore{db: db}
+	if opts.GCInterval > 0 || opts.MaxSize > 0 {
+		store.maintenance = startBadgerMaintenance(db, opts)
+	}
+	return store, nil
+}
+
+// toBadgerOptions maps our options onto those of BadgerDB.
+func toBadgerOptions(opts BadgerOptions) (badger.Options, error) {
+	badgerOpts := badger.DefaultOptions(opts.Path).
+		WithLoggingLevel(badger.WARNING).
+		WithInMemory(opts.InMemory).
+		WithSyncWrites(opts.SyncWrites).
+		WithIndexCacheSize(opts.IndexCacheSize)
+
+	if opts.BlockCacheSize > 0 {
+		badgerOpts = badgerOpts.WithBlockCacheSize(opts.BlockCacheSize)
+	}
+
+	switch strings.ToLower(opts.Compression) {
+	case "":
+		// Keep the default
+	case "none":
+		badgerOpts = badgerOpts.WithCompression(options.None)
+	case "snappy":
+		badgerOpts = badgerOpts.WithCompression(options.Snappy)
+	case "zstd":
+		badgerOpts = badgerOpts.WithCompression(options.ZSTD)
+	default:
+		return badgerOpts, fmt.Errorf("unknown BadgerDB compression %q, expected none, snappy or zstd", opts.Compression)
+	}
+
+	return badgerOpts, nil
+}
+
+// validateDataDirectory checks that a user-provided directory exists (creating it if necessary) and is writable.
+//
+// This gives clearer errors at startup, than BadgerDB would otherwise report.
+func validateDataDirectory(path string) error {
+	if err := os.MkdirAll(path, 0755); errors.Is(err, fs.ErrPermission) {
+		return fmt.Errorf("permission denied creating BadgerDB directory %s: %w", path, err)
+	} else if err != nil {
+		return fmt.Errorf("cannot create BadgerDB directory %s (does a file already exist at this path?): %w", path, err)
+	}
+
+	// Check permissions by creating (and deleting) a temporary file
+	probe, err := os.CreateTemp(path, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("BadgerDB directory %s is not writable: %w", path, err)
+	}
+	if err := probe.Close(); err != nil {
+		return err
+	}
+	return os.Remove(probe.Name())
 }
 
 // Determines a path to where the BadgerDB data is stored (created if not already existing)
@@ -61,10 +196,10 @@ func pathDataDirectory() (string, error) {
 }
 
 func (b *badgerStore) BuildKey(query string) string {
-	return fmt.Sprintf("geocode:%s", query)
+	return keyPrefix + query
 }
 
-func (b *badgerStore) Get(key string) ([]location.Location, error) {
+func (b *badgerStore) Get(_ context.Context, key string) ([]location.Location, error) {
 	var result []location.Location
 	err := b.db.View(func(txn *badger.Txn) error {
 		item, err := txn.Get([]byte(key))
@@ -89,7 +224,11 @@ func (b *badgerStore) Get(key string) ([]location.Location, error) {
 	return result, nil
 }
 
-func (b *badgerStore) Set(key string, locations []location.Location) error {
+func (b *badgerStore) Set(_ context.Context, key string, locations []location.Location) error {
+	if b.maintenance != nil && b.maintenance.full.Load() {
+		return ErrStoreFull
+	}
+
 	val, err := marshalLocations(locations)
 	if err != nil {
 		return err
@@ -99,10 +238,160 @@ func (b *badgerStore) Set(key string, locations []location.Location) error {
 	})
 }
 
-// Close closes the underlying BadgerDB.
+// ForEach iterates over all keys with the geocode prefix, in key order.
+func (b *badgerStore) ForEach(ctx context.Context, fn func(query string, value []location.Location) error) error {
+	return b.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(keyPrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			item := it.Item()
+			query := strings.TrimPrefix(string(item.Key()), keyPrefix)
+			err := item.Value(func(val []byte) error {
+				locs, err := unmarshalLocations(val)
+				if err != nil {
+					return fmt.Errorf("cannot parse value for key %s: %w", item.Key(), err)
+				}
+				return fn(query, locs)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Count iterates over the keys with the geocode prefix, without reading their values.
+func (b *badgerStore) Count(ctx context.Context) (int64, error) {
+	var count int64
+	err := b.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(keyPrefix)
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			count++
+		}
+		return ctx.Err()
+	})
+	return count, err
+}
+
+// Ping performs an empty read transaction, which fails if the DB has been closed.
+func (b *badgerStore) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return b.db.View(func(_ *badger.Txn) error {
+		return nil
+	})
+}
+
+// SetRecord stores the record under a key with the record prefix.
+func (b *badgerStore) SetRecord(_ context.Context, kind string, id string, value []byte) error {
+	return b.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(recordKindPrefix(kind)+id), value)
+	})
+}
+
+// GetRecord retrieves the record, or nil if not found.
+func (b *badgerStore) GetRecord(_ context.Context, kind string, id string) ([]byte, error) {
+	var value []byte
+	err := b.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(recordKindPrefix(kind) + id))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		value, err = item.ValueCopy(nil)
+		return err
+	})
+	return value, err
+}
+
+// SwapRecord reads and replaces the record in a single transaction, which fails if the record is concurrently changed.
+func (b *badgerStore) SwapRecord(_ context.Context, kind string, id string, old []byte, value []byte) (bool, error) {
+	key := []byte(recordKindPrefix(kind) + id)
+	swapped := false
+	err := b.db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err == badger.ErrKeyNotFound {
+			if old != nil {
+				return nil
+			}
+		} else if err != nil {
+			return err
+		} else {
+			current, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			} else if old == nil || !bytes.Equal(current, old) {
+				return nil
+			}
+		}
+		swapped = true
+		return txn.Set(key, value)
+	})
+	if err == badger.ErrConflict {
+		return false, nil
+	}
+	return swapped && err == nil, err
+}
+
+// DeleteRecord removes the record, if it exists.
+func (b *badgerStore) DeleteRecord(_ context.Context, kind string, id string) error {
+	return b.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(recordKindPrefix(kind) + id))
+	})
+}
+
+// ForEachRecord iterates over all keys with the prefix of the kind, in key order.
+func (b *badgerStore) ForEachRecord(ctx context.Context, kind string, fn func(id string, value []byte) error) error {
+	prefix := recordKindPrefix(kind)
+	return b.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(prefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			item := it.Item()
+			id := strings.TrimPrefix(string(item.Key()), prefix)
+			if err := item.Value(func(val []byte) error { return fn(id, val) }); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Backup writes a full backup using BadgerDB's stream-backup format, while the store remains online.
+func (b *badgerStore) Backup(w io.Writer) error {
+	_, err := b.db.Backup(w, 0)
+	return err
+}
+
+// Close stops any background maintenance, and then closes the underlying BadgerDB.
 func (b *badgerStore) Close() error {
+	if b.maintenance != nil {
+		b.maintenance.stop()
+	}
 	return b.db.Close()
 }
 
 // Assert implementation
 var _ LocationStore = (*badgerStore)(nil)
+var _ Backuper = (*badgerStore)(nil)
+var _ RecordStore = (*badgerStore)(nil)
@@ -0,0 +1,131 @@
+package store
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// sizeCheckInterval is how often the size is checked against the maximum, when garbage collection is disabled.
+const sizeCheckInterval = time.Minute
+
+// ErrStoreFull is returned when setting a value in a store that has reached its maximum size.
+var ErrStoreFull = errors.New("the location-store has reached its maximum size")
+
+// badgerMaintenance periodically runs value-log garbage collection on a BadgerDB, and checks its size.
+type badgerMaintenance struct {
+	db   *badger.DB
+	opts BadgerOptions
+
+	// Whether the store has reached its maximum size, as determined by the most recent check
+	full atomic.Bool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// startBadgerMaintenance starts a goroutine that maintains the db, until stop is called.
+func startBadgerMaintenance(db *badger.DB, opts BadgerOptions) *badgerMaintenance {
+	m := &badgerMaintenance{db: db, opts: opts, done: make(chan struct{})}
+
+	interval := opts.GCInterval
+	if interval <= 0 {
+		interval = sizeCheckInterval
+	}
+
+	m.checkSize()
+
+	m.wg.Add(1)
+	go m.run(interval)
+	return m
+}
+
+// run performs maintenance after every interval, until stop is called.
+func (m *badgerMaintenance) run(interval time.Duration) {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			if m.opts.GCInterval > 0 {
+				m.collectGarbage()
+			}
+			m.checkSize()
+		}
+	}
+}
+
+// collectGarbage rewrites value-log files until no more can be rewritten (or maintenance is stopped).
+func (m *badgerMaintenance) collectGarbage() {
+	rewritten := 0
+	for {
+		select {
+		case <-m.done:
+			return
+		default:
+		}
+
+		err := m.db.RunValueLogGC(m.opts.GCDiscardRatio)
+		if errors.Is(err, badger.ErrNoRewrite) || errors.Is(err, badger.ErrRejected) {
+			break
+		} else if err != nil {
+			log.Error().Err(err).Msg("BadgerDB value-log garbage collection failed")
+			break
+		}
+		rewritten++
+	}
+	log.Debug().Int("rewritten files", rewritten).Msg("BadgerDB value-log garbage collection completed")
+}
+
+// checkSize updates whether the store has reached its maximum size (if any).
+func (m *badgerMaintenance) checkSize() {
+	if m.opts.MaxSize <= 0 {
+		return
+	}
+
+	size, err := directorySize(m.opts.Path)
+	if err != nil {
+		log.Error().Err(err).Msg("Cannot determine the size of the BadgerDB store")
+		return
+	}
+
+	full := size >= m.opts.MaxSize
+	if full && !m.full.Load() {
+		log.Warn().Int64("size", size).Int64("max size", m.opts.MaxSize).Msg("BadgerDB store has reached its maximum size, new entries will not be cached")
+	}
+	m.full.Store(full)
+}
+
+// directorySize sums the disk space allocated to all files in a directory.
+func directorySize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		size += allocatedSize(info)
+		return nil
+	})
+	return size, err
+}
+
+// stop signals the maintenance goroutine to stop, and waits until it has.
+func (m *badgerMaintenance) stop() {
+	close(m.done)
+	m.wg.Wait()
+}
@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	location "github.com/owenfeehan/geocoding-nominatim-cache/location"
+)
+
+func TestBadgerMaintenanceStopsOnClose(t *testing.T) {
+	store, err := NewBadgerStoreWithOptions(BadgerOptions{Path: t.TempDir(), GCInterval: 10 * time.Millisecond, GCDiscardRatio: 0.5})
+	if err != nil {
+		t.Fatalf("Failed to create Badger store: %v", err)
+	}
+
+	// Allow garbage collection to run a few times, while entries are overwritten
+	for i := range 20 {
+		if err := store.Set(context.Background(), store.BuildKey("Brussels"), []location.Location{{DisplayName: string(rune('A' + i))}}); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := store.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
+
+func TestBadgerMaxSize(t *testing.T) {
+	store, err := NewBadgerStoreWithOptions(BadgerOptions{Path: t.TempDir(), MaxSize: 1})
+	if err != nil {
+		t.Fatalf("Failed to create Badger store: %v", err)
+	}
+	defer store.Close()
+
+	err = store.Set(context.Background(), store.BuildKey("Brussels"), []location.Location{{DisplayName: "Brussels, Belgium"}})
+	if !errors.Is(err, ErrStoreFull) {
+		t.Errorf("Expected ErrStoreFull, got %v", err)
+	}
+}
+
+func TestBadgerInvalidDiscardRatio(t *testing.T) {
+	_, err := NewBadgerStoreWithOptions(BadgerOptions{Path: t.TempDir(), GCInterval: time.Minute, GCDiscardRatio: 1.5})
+	if err == nil {
+		t.Error("Expected an error for an invalid discard ratio")
+	}
+}
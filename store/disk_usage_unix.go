@@ -0,0 +1,18 @@
+//go:build unix
+
+package store
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// allocatedSize returns the disk space allocated to a file.
+//
+// BadgerDB preallocates sparse value-log and memtable files, so the apparent file size greatly overstates the disk usage.
+func allocatedSize(info fs.FileInfo) int64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int64(stat.Blocks) * 512
+	}
+	return info.Size()
+}
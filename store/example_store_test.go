@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"fmt"
 
 	location "github.com/owenfeehan/geocoding-nominatim-cache/location"
@@ -13,13 +14,13 @@ func ExampleLocationStore() {
 	key := store.BuildKey("Brussels") // Find a key corresponding to the query Use query string directly as key
 
 	// Store locations
-	if err := store.Set(key, locations); err != nil {
+	if err := store.Set(context.Background(), key, locations); err != nil {
 		fmt.Println("Set failed:", err)
 		return
 	}
 
 	// Retrieve locations
-	got, err := store.Get(key)
+	got, err := store.Get(context.Background(), key)
 	if err != nil {
 		fmt.Println("Get failed:", err)
 		return
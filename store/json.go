@@ -1,21 +1,62 @@
 package store
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
+	"io"
 
 	location "github.com/owenfeehan/geocoding-nominatim-cache/location"
 )
 
+// gzipMagic starts every gzip stream, whereas JSON never starts with it, so compressed values can be distinguished.
+var gzipMagic = []byte{0x1f, 0x8b}
+
 // marshalLocations serializes a slice of Location to JSON.
+//
+// If any location has an outline, the JSON is compressed with gzip, as outlines are large but compress well.
 func marshalLocations(locs []location.Location) ([]byte, error) {
-	return json.Marshal(locs)
+	data, err := json.Marshal(locs)
+	if err != nil || !hasOutline(locs) {
+		return data, err
+	}
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
 }
 
-// unmarshalLocations deserializes JSON data into a slice of Location.
+// unmarshalLocations deserializes JSON data (optionally compressed with gzip) into a slice of Location.
 func unmarshalLocations(data []byte) ([]location.Location, error) {
+	if bytes.HasPrefix(data, gzipMagic) {
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = io.ReadAll(reader); err != nil {
+			return nil, err
+		}
+	}
+
 	var result []location.Location
 	if err := json.Unmarshal(data, &result); err != nil {
 		return nil, err
 	}
 	return result, nil
 }
+
+// hasOutline checks if any of the locations has an outline.
+func hasOutline(locs []location.Location) bool {
+	for _, loc := range locs {
+		if len(loc.GeoJSON) > 0 {
+			return true
+		}
+	}
+	return false
+}
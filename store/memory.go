@@ -1,6 +1,9 @@
 package store
 
 import (
+	"bytes"
+	"context"
+	"strings"
 	"sync"
 
 	location "github.com/owenfeehan/geocoding-nominatim-cache/location"
@@ -9,8 +12,9 @@ import (
 
 // memoryStore is an in-memory implementation of LocationStore using a map and mutex for thread safety.
 type memoryStore struct {
-	mu    sync.RWMutex                   // protects store
-	store map[string][]location.Location // cache storage
+	mu      sync.RWMutex                   // protects store and records
+	store   map[string][]location.Location // cache storage
+	records map[string][]byte              // records, keyed by kind and id
 }
 
 // NewMemoryStore creates a new in-memory-only implementation of LocationStore
@@ -21,7 +25,8 @@ type memoryStore struct {
 func NewMemoryStore() LocationStore {
 	log.Info().Msg("Using in-memory store for locations")
 	return &memoryStore{
-		store: make(map[string][]location.Location),
+		store:   make(map[string][]location.Location),
+		records: make(map[string][]byte),
 	}
 }
 
@@ -31,7 +36,7 @@ func (c *memoryStore) BuildKey(query string) string {
 }
 
 // Get retrieves the cached locations for the given key, or nil if not found.
-func (c *memoryStore) Get(key string) ([]location.Location, error) {
+func (c *memoryStore) Get(_ context.Context, key string) ([]location.Location, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	if val, ok := c.store[key]; ok {
@@ -41,17 +46,108 @@ func (c *memoryStore) Get(key string) ([]location.Location, error) {
 }
 
 // Set stores the locations in the cache under the given key.
-func (c *memoryStore) Set(key string, locations []location.Location) error {
+func (c *memoryStore) Set(_ context.Context, key string, locations []location.Location) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.store[key] = locations
 	return nil
 }
 
+// ForEach calls fn on a snapshot of the entries, so fn may safely read or write the store.
+func (c *memoryStore) ForEach(ctx context.Context, fn func(query string, value []location.Location) error) error {
+	c.mu.RLock()
+	snapshot := make(map[string][]location.Location, len(c.store))
+	for key, val := range c.store {
+		snapshot[key] = val
+	}
+	c.mu.RUnlock()
+
+	for key, val := range snapshot {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(key, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Count returns the number of entries in the map.
+func (c *memoryStore) Count(_ context.Context) (int64, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return int64(len(c.store)), nil
+}
+
+// Ping is a no-op for memoryStore, which is always reachable.
+func (c *memoryStore) Ping(_ context.Context) error {
+	return nil
+}
+
 // Close is a no-op for memoryStore.
 func (c *memoryStore) Close() error {
 	return nil
 }
 
+// SetRecord stores the record in a separate map to the cache.
+func (c *memoryStore) SetRecord(_ context.Context, kind string, id string, value []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.records[recordKindPrefix(kind)+id] = value
+	return nil
+}
+
+// GetRecord retrieves the record, or nil if not found.
+func (c *memoryStore) GetRecord(_ context.Context, kind string, id string) ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.records[recordKindPrefix(kind)+id], nil
+}
+
+// SwapRecord replaces the record while holding the lock.
+func (c *memoryStore) SwapRecord(_ context.Context, kind string, id string, old []byte, value []byte) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	current, ok := c.records[recordKindPrefix(kind)+id]
+	if ok != (old != nil) || !bytes.Equal(current, old) {
+		return false, nil
+	}
+	c.records[recordKindPrefix(kind)+id] = value
+	return true, nil
+}
+
+// DeleteRecord removes the record, if it exists.
+func (c *memoryStore) DeleteRecord(_ context.Context, kind string, id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.records, recordKindPrefix(kind)+id)
+	return nil
+}
+
+// ForEachRecord calls fn on a snapshot of the records, so fn may safely read or write the store.
+func (c *memoryStore) ForEachRecord(ctx context.Context, kind string, fn func(id string, value []byte) error) error {
+	prefix := recordKindPrefix(kind)
+	c.mu.RLock()
+	snapshot := make(map[string][]byte)
+	for key, val := range c.records {
+		if id, ok := strings.CutPrefix(key, prefix); ok {
+			snapshot[id] = val
+		}
+	}
+	c.mu.RUnlock()
+
+	for id, val := range snapshot {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(id, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Assert implementation of LocationStore interface.
 var _ LocationStore = (*memoryStore)(nil)
+var _ RecordStore = (*memoryStore)(nil)
@@ -0,0 +1,37 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// pingRetryInterval is the delay between successive pings in PingWithRetry.
+const pingRetryInterval = time.Second
+
+// PingWithRetry pings the store until it succeeds, or until the window has elapsed.
+//
+// This allows the store to become reachable shortly after startup, as often occurs with containers started together.
+//
+// The error from the final ping is returned, if the store never became reachable.
+func PingWithRetry(ctx context.Context, locStore LocationStore, window time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, window)
+	defer cancel()
+
+	for attempt := 1; ; attempt++ {
+		err := locStore.Ping(ctx)
+		if err == nil {
+			return nil
+		}
+
+		log.Warn().Err(err).Int("attempt", attempt).Msg("Location-store is not reachable")
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("location-store was not reachable within %v: %w", window, err)
+		case <-time.After(pingRetryInterval):
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package store
+
+import (
+	"context"
+	"errors"
+)
+
+// recordPrefix is prepended to the keys of records, so they never clash with the keys of cached locations.
+const recordPrefix = "record:"
+
+// ErrRecordsUnsupported is returned when records are required from a store that does not implement RecordStore.
+var ErrRecordsUnsupported = errors.New("the store does not support records")
+
+// RecordStore is implemented by stores that can also hold arbitrary records (e.g. API keys), separately from the cached locations.
+//
+// Records are grouped by kind, and identified by an id that is unique within the kind. Records are not visited by
+// LocationStore.ForEach or counted by LocationStore.Count.
+type RecordStore interface {
+	// SetRecord creates or replaces a record.
+	SetRecord(ctx context.Context, kind string, id string, value []byte) error
+
+	// GetRecord retrieves a record, or nil if it does not exist.
+	GetRecord(ctx context.Context, kind string, id string) ([]byte, error)
+
+	// SwapRecord replaces a record with value only if it still holds old (or, if old is nil, creates it only if it does
+	// not exist), returning whether it was replaced. This is atomic, even between services sharing the store.
+	SwapRecord(ctx context.Context, kind string, id string, old []byte, value []byte) (bool, error)
+
+	// DeleteRecord removes a record, if it exists.
+	DeleteRecord(ctx context.Context, kind string, id string) error
+
+	// ForEachRecord calls fn for every record of a kind, in no particular order, stopping at the first error.
+	ForEachRecord(ctx context.Context, kind string, fn func(id string, value []byte) error) error
+}
+
+// recordKindPrefix is the prefix of the keys of all records of a kind.
+func recordKindPrefix(kind string) string {
+	return recordPrefix + kind + ":"
+}
@@ -8,7 +8,14 @@
 // persistent storage.
 package store
 
-import "github.com/owenfeehan/geocoding-nominatim-cache/location"
+import (
+	"context"
+
+	"github.com/owenfeehan/geocoding-nominatim-cache/location"
+)
+
+// keyPrefix is prepended to queries to form keys in the persistent (shared) backends.
+const keyPrefix = "geocode:"
 
 // LocationStore defines the interface for getting and putting data in the cache-backend.
 type LocationStore interface {
@@ -16,10 +23,21 @@ type LocationStore interface {
 	BuildKey(query string) string
 
 	// Stores a location-values for a given key
-	Set(key string, value []location.Location) error
+	Set(ctx context.Context, key string, value []location.Location) error
 
 	// Retrieves a location-values for a given key
-	Get(key string) ([]location.Location, error)
+	Get(ctx context.Context, key string) ([]location.Location, error)
+
+	// Calls fn for every entry in the store, with the query (recovered from the key) and its location-values.
+	//
+	// Iteration stops at the first error returned by fn, which is then returned.
+	ForEach(ctx context.Context, fn func(query string, value []location.Location) error) error
+
+	// Counts the entries in the store
+	Count(ctx context.Context) (int64, error)
+
+	// Checks that the store is reachable and usable, returning an error if not
+	Ping(ctx context.Context) error
 
 	// Closes the store and releases any resources (no-op for in-memory)
 	Close() error
@@ -1,7 +1,14 @@
 package store
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	location "github.com/owenfeehan/geocoding-nominatim-cache/location"
@@ -21,8 +28,75 @@ func TestBadgerStore(t *testing.T) {
 	testWithStore(t, store)
 }
 
+func TestBadgerStoreInMemory(t *testing.T) {
+	store, err := NewBadgerStoreWithOptions(BadgerOptions{InMemory: true, Compression: "zstd"})
+	if err != nil {
+		t.Fatalf("Failed to create Badger store: %v", err)
+	}
+	defer store.Close()
+	testWithStore(t, store)
+}
+
+func TestBadgerStoreLocked(t *testing.T) {
+	path := t.TempDir()
+	createBadgerStore(t, path)
+
+	_, err := NewBadgerStore(&path)
+	if !errors.Is(err, ErrLocked) || !strings.Contains(err.Error(), "locked by another process") {
+		t.Errorf("Expected an error that the directory is locked, got %v", err)
+	}
+}
+
+func TestBadgerStoreInvalidDirectory(t *testing.T) {
+	// A path that exists as a file, rather than a directory
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatalf("Cannot create file: %v", err)
+	}
+
+	if _, err := NewBadgerStore(&path); err == nil {
+		t.Error("Expected an error for a path that is not a directory")
+	}
+}
+
+func TestBadgerStoreInvalidCompression(t *testing.T) {
+	if _, err := NewBadgerStoreWithOptions(BadgerOptions{Path: t.TempDir(), Compression: "lz4"}); err == nil {
+		t.Error("Expected an error for an unknown compression")
+	}
+}
+
+func TestMarshalLocationsCompressesOutlines(t *testing.T) {
+	var coordinates []string
+	for i := range 1000 {
+		coordinates = append(coordinates, fmt.Sprintf("[%d.5,50.5]", i%180))
+	}
+	outline := json.RawMessage(`{"type":"LineString","coordinates":[` + strings.Join(coordinates, ",") + `]}`)
+	locs := []location.Location{{DisplayName: "Brussels", GeoJSON: outline}}
+
+	data, err := marshalLocations(locs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uncompressed, _ := json.Marshal(locs); len(data) >= len(uncompressed)/2 {
+		t.Errorf("expected the outline to be compressed, got %d bytes from %d", len(data), len(uncompressed))
+	}
+	got, err := unmarshalLocations(data)
+	if err != nil || !reflect.DeepEqual(got, locs) {
+		t.Errorf("expected the locations to be restored, got %v (%v)", got, err)
+	}
+
+	// Locations without outlines are not compressed, as before
+	if data, _ := marshalLocations([]location.Location{{DisplayName: "Paris"}}); !json.Valid(data) {
+		t.Errorf("expected JSON without an outline, got %q", data)
+	}
+}
+
 // testWithStore tests the provided LocationStore implementation by performing a series of queries and checking the results.
 func testWithStore(t *testing.T, store LocationStore) {
+	if err := store.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+
 	// Query that returns no locations
 	testLocation(t, store, "Unknown place", []location.Location{})
 
@@ -33,6 +107,109 @@ func testWithStore(t *testing.T, store LocationStore) {
 	// Query that returns two locations
 	locationWisconsin := location.Location{DisplayName: "Brussels, Wisconsin", Latitude: "10.8503", Longitude: "14.3517"}
 	testLocation(t, store, "Brussels", []location.Location{locationBelgium, locationWisconsin})
+
+	// Query whose location has an outline (which is compressed)
+	locationBelgium.GeoJSON = json.RawMessage(`{"type":"Polygon","coordinates":[[[4.31,50.79],[4.43,50.79],[4.43,50.91],[4.31,50.79]]]}`)
+	testLocation(t, store, "Brussels", []location.Location{locationBelgium, locationWisconsin})
+
+	// Records are kept separately, so are not iterated or counted with the locations
+	testRecords(t, store)
+
+	// Iterating recovers the queries from the keys
+	testForEach(t, store, map[string]int{"unknown place": 0, "brussels": 2})
+
+	count, err := store.Count(context.Background())
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 entries, got %d", count)
+	}
+}
+
+// testRecords sets, retrieves, iterates and deletes records in the store.
+func testRecords(t *testing.T, store LocationStore) {
+	records, ok := store.(RecordStore)
+	if !ok {
+		t.Fatal("Expected the store to implement RecordStore")
+	}
+	ctx := context.Background()
+
+	for id, value := range map[string]string{"a": "first", "b": "second"} {
+		if err := records.SetRecord(ctx, "test", id, []byte(value)); err != nil {
+			t.Fatalf("SetRecord failed: %v", err)
+		}
+	}
+	if err := records.SetRecord(ctx, "other", "c", []byte("third")); err != nil {
+		t.Fatalf("SetRecord failed: %v", err)
+	}
+	if err := records.DeleteRecord(ctx, "test", "b"); err != nil {
+		t.Fatalf("DeleteRecord failed: %v", err)
+	}
+
+	value, err := records.GetRecord(ctx, "test", "a")
+	if err != nil || string(value) != "first" {
+		t.Errorf("Expected record a to be first, got %q (%v)", value, err)
+	}
+	value, err = records.GetRecord(ctx, "test", "b")
+	if err != nil || value != nil {
+		t.Errorf("Expected deleted record b to be nil, got %q (%v)", value, err)
+	}
+
+	// Swaps only succeed from the current value, or if creating a record that does not exist
+	if err := records.SetRecord(ctx, "swap", "a", []byte("first")); err != nil {
+		t.Fatalf("SetRecord failed: %v", err)
+	}
+	for _, swap := range []struct {
+		id, old, value string
+		want           bool
+	}{
+		{"a", "second", "fourth", false},
+		{"a", "first", "fifth", true},
+		{"a", "first", "sixth", false},
+		{"d", "", "seventh", true},
+		{"d", "", "eighth", false},
+	} {
+		var old []byte
+		if swap.old != "" {
+			old = []byte(swap.old)
+		}
+		swapped, err := records.SwapRecord(ctx, "swap", swap.id, old, []byte(swap.value))
+		if err != nil || swapped != swap.want {
+			t.Errorf("Expected swap of %s from %q to %q to be %v, got %v (%v)", swap.id, swap.old, swap.value, swap.want, swapped, err)
+		}
+	}
+	value, err = records.GetRecord(ctx, "swap", "a")
+	if err != nil || string(value) != "fifth" {
+		t.Errorf("Expected swapped record a to be fifth, got %q (%v)", value, err)
+	}
+
+	got := make(map[string]string)
+	err = records.ForEachRecord(ctx, "test", func(id string, value []byte) error {
+		got[id] = string(value)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachRecord failed: %v", err)
+	}
+	if want := map[string]string{"a": "first"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected records %v, got %v", want, got)
+	}
+}
+
+// testForEach checks that ForEach visits exactly the expected (case-insensitive) queries, with the expected number of locations.
+func testForEach(t *testing.T, store LocationStore, want map[string]int) {
+	got := make(map[string]int)
+	err := store.ForEach(context.Background(), func(query string, value []location.Location) error {
+		got[strings.ToLower(query)] = len(value)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEach failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
 }
 
 // testLocation tests the LocationStore implementation by storing and retrieving locations for a given query.
@@ -40,13 +217,13 @@ func testLocation(t *testing.T, store LocationStore, query string, locs []locati
 	key := store.BuildKey(query)
 
 	// Store locations in the cache
-	err := store.Set(key, locs)
+	err := store.Set(context.Background(), key, locs)
 	if err != nil {
 		t.Fatalf("Set failed: %v", err)
 	}
 
 	// Retrieve locations from the cache
-	got, err := store.Get(key)
+	got, err := store.Get(context.Background(), key)
 	if err != nil {
 		t.Fatalf("Get failed: %v", err)
 	}
@@ -0,0 +1,70 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/owenfeehan/geocoding-nominatim-cache/location"
+	"github.com/owenfeehan/geocoding-nominatim-cache/store"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans created by this package (with the global tracer-provider).
+const instrumentationName = "github.com/owenfeehan/geocoding-nominatim-cache/tracing"
+
+// tracedStore wraps a LocationStore, creating a span for each get and set.
+//
+// Other methods are passed directly to the delegate.
+type tracedStore struct {
+	store.LocationStore
+
+	// The type of backend, recorded as an attribute of each span
+	backend string
+}
+
+// InstrumentStore wraps a LocationStore to trace gets and sets, with the type of backend (e.g. badger or redis) as an attribute.
+func InstrumentStore(delegate store.LocationStore, backend string) store.LocationStore {
+	return &tracedStore{LocationStore: delegate, backend: backend}
+}
+
+// Get traces the delegate's Get, recording whether it was a cache hit.
+func (s *tracedStore) Get(ctx context.Context, key string) ([]location.Location, error) {
+	ctx, span := s.startSpan(ctx, "store.get", key)
+	defer span.End()
+
+	locs, err := s.LocationStore.Get(ctx, key)
+	span.SetAttributes(attribute.Bool("cache.hit", locs != nil))
+	recordError(span, err)
+	return locs, err
+}
+
+// Set traces the delegate's Set.
+func (s *tracedStore) Set(ctx context.Context, key string, value []location.Location) error {
+	ctx, span := s.startSpan(ctx, "store.set", key)
+	defer span.End()
+
+	err := s.LocationStore.Set(ctx, key, value)
+	recordError(span, err)
+	return err
+}
+
+// startSpan starts a client span for an operation on the store.
+func (s *tracedStore) startSpan(ctx context.Context, name string, key string) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system", s.backend), attribute.String("cache.key", key)),
+	)
+}
+
+// recordError marks the span as failed, if err is non-nil.
+func recordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// Assert implementation
+var _ store.LocationStore = (*tracedStore)(nil)
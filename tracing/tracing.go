@@ -0,0 +1,53 @@
+// Package tracing configures OpenTelemetry tracing, exporting spans via OTLP.
+//
+// Stores are traced by wrapping them in a decorator. Other packages create their own spans using the global
+// tracer-provider, which is a no-op unless Setup is called.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// ServiceName identifies this service in exported spans.
+const ServiceName = "geocoding-nominatim-cache"
+
+// Setup exports spans via OTLP over HTTP to the endpoint, by registering a global tracer-provider.
+//
+// endpoint is a URL e.g. http://localhost:4318 (or https:// for TLS). sampleRatio is the fraction of traces to sample,
+// between 0 and 1.
+//
+// The returned function flushes any remaining spans, and should be called before the application exits.
+func Setup(ctx context.Context, endpoint string, sampleRatio float64, version string) (func(context.Context) error, error) {
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(ServiceName),
+		semconv.ServiceVersion(version),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe the service for tracing: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
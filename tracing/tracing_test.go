@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/owenfeehan/geocoding-nominatim-cache/fetcher"
+	"github.com/owenfeehan/geocoding-nominatim-cache/location"
+	"github.com/owenfeehan/geocoding-nominatim-cache/store"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// stubFetcher returns a single location for every query.
+type stubFetcher struct{}
+
+func (stubFetcher) Fetch(_ context.Context, req fetcher.Request) ([]location.Location, error) {
+	return []location.Location{{DisplayName: req.Query()}}, nil
+}
+
+func TestInstrumentStore(t *testing.T) {
+	recorder := recordSpans(t)
+
+	locStore := InstrumentStore(store.NewMemoryStore(), "memory")
+	_ = locStore.Set(context.Background(), "Brussels", []location.Location{{DisplayName: "Brussels"}})
+	_, _ = locStore.Get(context.Background(), "Brussels")
+
+	assertSpans(t, recorder, "store.set", "store.get")
+}
+
+func TestThrottlerWaitSpan(t *testing.T) {
+	recorder := recordSpans(t)
+
+	// The throttler uses the global tracer-provider, so needs no decorator
+	throttler := fetcher.NewThrottler(stubFetcher{}, time.Millisecond)
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	_, _ = throttler.Fetch(ctx, fetcher.Search("Brussels"))
+	parent.End()
+
+	assertSpans(t, recorder, "throttler.wait", "parent")
+	if got := recorder.Ended()[0].Parent().SpanID(); got != parent.SpanContext().SpanID() {
+		t.Errorf("expected the wait to be a child of the parent span")
+	}
+}
+
+// recordSpans records spans in-process, by registering a global tracer-provider for the duration of the test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+// assertSpans asserts the names of the ended spans, in the order they ended.
+func assertSpans(t *testing.T, recorder *tracetest.SpanRecorder, want ...string) {
+	t.Helper()
+	var got []string
+	for _, span := range recorder.Ended() {
+		got = append(got, span.Name())
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected spans %v, got %v", want, got)
+	}
+}